@@ -96,6 +96,67 @@ func ParseAnnot(src string) (Annot, error) {
 
 }
 
+// ParseDDLComment parses the comment of a table or column in DDL. Text before the
+// first annotation is plain description, the rest are annotations each starts with '$':
+//   User's id. $type:github.com/google/uuid.UUID $name:UserID
+// Only '$' followed by a registered annotation name starts an annotation, so that
+// comments like "Price in US$" are kept as description. '$' inside double quotes
+// does not start a new annotation.
+func ParseDDLComment(src string) (string, []Annot, error) {
+
+	// Split by '$' starting annotations.
+	text := src
+	parts := []string{}
+	quoted := false
+	start := -1
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\\' && quoted:
+			i += 1
+		case c == '"':
+			quoted = !quoted
+		case c == '$' && !quoted && isAnnotStart(src[i+1:]):
+			if start >= 0 {
+				parts = append(parts, src[start:i])
+			} else {
+				text = src[:i]
+			}
+			start = i + 1
+		}
+	}
+	if start >= 0 {
+		if quoted {
+			return "", nil, fmt.Errorf("Unclosed quote in comment %+q", src)
+		}
+		parts = append(parts, src[start:])
+	}
+
+	annots := make([]Annot, 0, len(parts))
+	for _, part := range parts {
+		annot, err := ParseAnnot(part)
+		if err != nil {
+			return "", nil, err
+		}
+		annots = append(annots, annot)
+	}
+
+	return strings.TrimSpace(text), annots, nil
+
+}
+
+var annotNameRe *regexp.Regexp = regexp.MustCompile(`^([A-Za-z][0-9A-Za-z_]*)(:|\s|$)`)
+
+// Return true if src starts with a registered annotation name.
+func isAnnotStart(src string) bool {
+	m := annotNameRe.FindStringSubmatch(src)
+	if m == nil {
+		return false
+	}
+	_, ok := annotMap[m[1]]
+	return ok
+}
+
 var (
 	annotRe  *regexp.Regexp = regexp.MustCompile(`^([A-Za-z][0-9A-Za-z_]*)(:(("[^"\\]*(?:\\.[^"\\]*)*")|([^\s:"]+)))?\s+`)
 	escapeRe *regexp.Regexp = regexp.MustCompile(`\\.`)
@@ -3,6 +3,7 @@ package annot
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -31,6 +32,299 @@ func TestParsing(t *testing.T) {
 	testAnnot(t, "func:\"a\\cb\"", &FuncAnnot{
 		Name: "acb",
 	}, false)
+	testAnnot(t, "func:F return:scalar", &FuncAnnot{
+		Name:        "F",
+		ReturnStyle: ReturnScalar,
+	}, false)
+	testAnnot(t, "func:F return:lastInsertId", &FuncAnnot{
+		Name:        "F",
+		ReturnStyle: ReturnLastInsertId,
+	}, false)
+	testAnnot(t, "func:F return:all", nil, true)
+	testAnnot(t, "func:F return:map key:u.id multi", &FuncAnnot{
+		Name:        "F",
+		ReturnStyle: ReturnMap,
+		Key:         "u.id",
+		Multi:       true,
+	}, false)
+	testAnnot(t, "func:F return:map", nil, true)
+	testAnnot(t, "func:F key:id", nil, true)
+	testAnnot(t, "func:F unwrap", &FuncAnnot{
+		Name:   "F",
+		Unwrap: true,
+	}, false)
+	testAnnot(t, "func:F signature:params", &FuncAnnot{
+		Name:      "F",
+		Signature: "params",
+	}, false)
+	testAnnot(t, "func:F signature:struct", nil, true)
+	testAnnot(t, "tags:json,yaml naming:camel omitempty", &TagsAnnot{
+		Names:     []string{"json", "yaml"},
+		Naming:    "camel",
+		OmitEmpty: true,
+	}, false)
+	testAnnot(t, "tags", &TagsAnnot{
+		Names:  []string{},
+		Naming: "snake",
+	}, false)
+	testAnnot(t, "tags:json naming:kebab", nil, true)
+	testAnnot(t, "group:u children:b,C", &GroupAnnot{
+		Parent:   "u",
+		Children: []string{"b", "c"},
+	}, false)
+	testAnnot(t, "group:u", nil, true)
+	testAnnot(t, "group children:b", nil, true)
+	testAnnot(t, "result:UserBrief", &ResultAnnot{
+		Name: "UserBrief",
+	}, false)
+	testAnnot(t, "result:\"a b\"", nil, true)
+	testAnnot(t, "arg:limit type:int default:20", &ArgAnnot{
+		Name:     "limit",
+		Type:     "int",
+		Optional: true,
+		Default:  "20",
+	}, false)
+	testAnnot(t, "arg:title optional", &ArgAnnot{
+		Name:     "title",
+		Optional: true,
+	}, false)
+	testAnnot(t, "arg:title optional:yes", nil, true)
+	testAnnot(t, "optional:title if:hasTitle", &OptionalAnnot{
+		Name: "title",
+		If:   "hasTitle",
+	}, false)
+	testAnnot(t, "optional", nil, true)
+	testAnnot(t, "end", &EndAnnot{}, false)
+	testAnnot(t, "end:x", nil, true)
+	testAnnot(t, "orderBy:sort allow:\"u.id, title\"", &OrderByAnnot{
+		Name:  "sort",
+		Allow: []string{"u.id", "title"},
+	}, false)
+	testAnnot(t, "orderBy:sort", nil, true)
+	testAnnot(t, "orderBy:sort allow:\"id;drop\"", nil, true)
+	testAnnot(t, "orderBy:sort allow:\"u.id,u_id\"", nil, true)
+	testAnnot(t, "paginate by:\"b.fill_time, b.id\" desc", &PaginateAnnot{
+		By:   []string{"b.fill_time", "b.id"},
+		Desc: true,
+	}, false)
+	testAnnot(t, "paginate", nil, true)
+	testAnnot(t, "page", &PageAnnot{}, false)
+	testAnnot(t, "page size:10", nil, true)
+	testAnnot(t, "paginate:x by:id", nil, true)
+	testAnnot(t, "tx:CreateBlog", &TxAnnot{
+		Name: "CreateBlog",
+	}, false)
+	testAnnot(t, "tx", nil, true)
+	testAnnot(t, "tx:CreateBlog isolation:serializable", nil, true)
+	testAnnot(t, "batch:names,ages", &BatchAnnot{
+		Args: []string{"names", "ages"},
+	}, false)
+	testAnnot(t, "batch", nil, true)
+}
+
+func testDDLComment(t *testing.T, src string, expectText string, expectAnnots []Annot, expectErr bool) {
+	text, annots, err := ParseDDLComment(src)
+	fmt.Printf("%q:\n\texpect=%q %#v\n\tresult=%q %#v\n\texpectErr=%v\n\terr=%v\n",
+		src, expectText, expectAnnots, text, annots, expectErr, err)
+
+	if (err != nil && !expectErr) || (err == nil && expectErr) {
+		t.Errorf("%q: err != expectErr\n", src)
+		return
+	}
+	if err != nil {
+		return
+	}
+	if text != expectText || !reflect.DeepEqual(annots, expectAnnots) {
+		t.Errorf("%q: result != expect\n", src)
+		return
+	}
+}
+
+func TestDDLComment(t *testing.T) {
+	fmt.Println("TestDDLComment")
+	testDDLComment(t, "", "", []Annot{}, false)
+	testDDLComment(t, "User's id.", "User's id.", []Annot{}, false)
+	testDDLComment(t, "User's id. $type:github.com/google/uuid.UUID $name:UserID", "User's id.", []Annot{
+		&TypeAnnot{Type: "github.com/google/uuid.UUID"},
+		&NameAnnot{Name: "UserID"},
+	}, false)
+	testDDLComment(t, "$type:\"a$b\"", "", []Annot{
+		&TypeAnnot{Type: "a$b"},
+	}, false)
+	testDDLComment(t, "$name:\"a", "", nil, true)
+	testDDLComment(t, "$name:1a", "", nil, true)
+	// '$' not followed by a registered annotation name is plain text.
+	testDDLComment(t, "$$", "$$", []Annot{}, false)
+	testDDLComment(t, "$unknown", "$unknown", []Annot{}, false)
+	testDDLComment(t, "Price in US$", "Price in US$", []Annot{}, false)
+	testDDLComment(t, "Costs $5. $names", "Costs $5. $names", []Annot{}, false)
+	testDDLComment(t, "Costs $5. $name:Cost", "Costs $5.", []Annot{
+		&NameAnnot{Name: "Cost"},
+	}, false)
+	// Quotes are tracked from the start.
+	testDDLComment(t, "Use \"$name:X\" to rename.", "Use \"$name:X\" to rename.", []Annot{}, false)
+	testDDLComment(t, "Screen size in \" (inch) $name:X", "Screen size in \" (inch) $name:X", []Annot{}, false)
+}
+
+func TestAnnotMeta(t *testing.T) {
+	fmt.Println("TestAnnotMeta")
+	src := "-- $func:F\n-- $arg:id\n-- $arg:xs\nSELECT * FROM t WHERE id=/*$bind:id*/1/**/ AND x IN (/*$bind:xs*/'a','b'/**/)"
+	meta, err := NewAnnotMeta(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Text != "SELECT * FROM t WHERE id=:id AND x IN (:xs)" {
+		t.Errorf("Unexpected text %q\n", meta.Text)
+	}
+	if len(meta.Bindings) != 2 {
+		t.Fatalf("Expect 2 bindings but got %d\n", len(meta.Bindings))
+	}
+	for i, expect := range []string{"1", "'a','b'"} {
+		b := meta.Bindings[i]
+		if content := src[b.Offset : b.Offset+b.Length]; content != expect {
+			t.Errorf("Binding %d: %q != %q\n", i, content, expect)
+		}
+	}
+	if meta.Arg("id") == nil || meta.Arg("y") != nil {
+		t.Errorf("Unexpected Arg result\n")
+	}
+}
+
+func testValidate(t *testing.T, src string, expectError bool) {
+	_, err := NewAnnotMeta(src)
+	if expectError {
+		if err == nil {
+			t.Errorf("Expect error for %q\n", src)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("Unexpected error for %q: %s\n", src, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fmt.Println("TestValidate")
+	// Bind without arg.
+	testValidate(t, "SELECT * FROM t WHERE id=/*$bind:id*/1/**/", true)
+	// Unused arg.
+	testValidate(t, "-- $arg:id\n-- $arg:x\nSELECT * FROM t WHERE id=/*$bind:id*/1/**/", true)
+	// Duplicate arg.
+	testValidate(t, "-- $arg:id\n-- $arg:id\nSELECT * FROM t WHERE id=/*$bind:id*/1/**/", true)
+	// Unknown template variable.
+	testValidate(t, "-- $arg:id\nSELECT * FROM t WHERE 1 /*$${{ if .x }}*/AND id=/*$bind:id*/1/**//*$${{ end }}*/", true)
+	testValidate(t, "-- $arg:id\nSELECT * FROM t /*$${{ $.x }}*/ WHERE id=/*$bind:id*/1/**/", true)
+	// Invalid template.
+	testValidate(t, "-- $arg:id\nSELECT * FROM t WHERE id=/*$bind:id*/1/**/ /*$${{ if .id }}*/", true)
+
+	testValidate(t, "SELECT * FROM t", false)
+	testValidate(t, "-- $arg:id\n-- $arg:x\nSELECT * FROM t WHERE 1 /*$${{ if .x }}*/AND id=/*$bind:id*/1/**//*$${{ end }}*/", false)
+	// Arg only used in template.
+	testValidate(t, "-- $arg:x\nSELECT * FROM t WHERE 1 /*$${{ if $.x }}*/AND 1/*$${{ end }}*/", false)
+	// Dot is rebound inside range/with.
+	testValidate(t, "-- $arg:xs\nSELECT * FROM t WHERE 1 /*$${{ range .xs }}{{ .y }}{{ end }}*/", false)
+}
+
+func TestVariants(t *testing.T) {
+	fmt.Println("TestVariants")
+	src := "-- $arg:a\n-- $arg:b\nSELECT * FROM t WHERE 1 /*$${{ if .a }}*/AND a=/*$bind:a*/1/**/ /*$${{ end }}*/" +
+		"/*$${{ if .b }}*/AND b=/*$bind:b*/'x'/**//*$${{ else }}*/AND b IS NULL/*$${{ end }}*/"
+	meta, err := NewAnnotMeta(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants, err := meta.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := map[string]bool{
+		"SELECT * FROM t WHERE 1 AND b IS NULL":         true,
+		"SELECT * FROM t WHERE 1 AND a=1 AND b IS NULL": true,
+		"SELECT * FROM t WHERE 1 AND b='x'":             true,
+		"SELECT * FROM t WHERE 1 AND a=1 AND b='x'":     true,
+	}
+	if len(variants) != len(expects) {
+		t.Fatalf("Expect %d variants but got %d\n", len(expects), len(variants))
+	}
+	for _, variant := range variants {
+		fmt.Printf("%q %q\n", variant.Text, variant.Choices)
+		if !expects[variant.Text] {
+			t.Errorf("Unexpected variant %q\n", variant.Text)
+		}
+	}
+
+	// Non-condition actions are not supported.
+	meta, err = NewAnnotMeta("-- $arg:a\nSELECT * FROM t ORDER BY /*$${{ .a }}*/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := meta.Variants(); err == nil {
+		t.Errorf("Expect error for actions\n")
+	}
+
+	if n := len(branchChoices(10, 16)); n != 16 {
+		t.Errorf("Expect 16 sampled choices but got %d\n", n)
+	}
+}
+
+func testOptional(t *testing.T, src string, expects []string) {
+	meta, err := NewAnnotMeta(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants, err := meta.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, variant := range variants {
+		fmt.Printf("%q %q\n", variant.Text, variant.Choices)
+		got[variant.Text] = true
+	}
+	if len(got) != len(expects) {
+		t.Errorf("Expect %d variants but got %d\n", len(expects), len(got))
+	}
+	for _, expect := range expects {
+		if !got[expect] {
+			t.Errorf("Missing variant %q\n", expect)
+		}
+	}
+}
+
+func TestOptional(t *testing.T) {
+	fmt.Println("TestOptional")
+
+	// WHERE is dropped if no predicate is left.
+	testOptional(t, "-- $arg:a\n-- $arg:b\n-- $arg:hasB\nSELECT * FROM t WHERE "+
+		"/*$optional:a*/a=/*$bind:a*/1/**//*$end*/ "+
+		"/*$optional:b if:hasB*/AND b=/*$bind:b*/'x'/**//*$end*/ ORDER BY id", []string{
+		"SELECT * FROM t    ORDER BY id",
+		"SELECT * FROM t WHERE a=1  ORDER BY id",
+		"SELECT * FROM t WHERE   b='x' ORDER BY id",
+		"SELECT * FROM t WHERE a=1 AND b='x' ORDER BY id",
+	})
+
+	// Leading AND of the following predicate is dropped if no predicate is included.
+	testOptional(t, "-- $arg:a\nSELECT * FROM t WHERE /*$optional:a*/a=/*$bind:a*/1/**//*$end*/ AND id=1", []string{
+		"SELECT * FROM t WHERE   id=1",
+		"SELECT * FROM t WHERE a=1 AND id=1",
+	})
+
+	// Not at the start of a predicate list.
+	testOptional(t, "-- $arg:a\nSELECT * FROM t WHERE id=1 /*$optional:a*/AND a=/*$bind:a*/1/**//*$end*/", []string{
+		"SELECT * FROM t WHERE id=1 ",
+		"SELECT * FROM t WHERE id=1 AND a=1",
+	})
+
+	for _, src := range []string{
+		"-- $arg:a\nSELECT * FROM t WHERE /*$optional:a*/a=/*$bind:a*/1/**/",
+		"-- $arg:a\nSELECT * FROM t WHERE a=/*$bind:a*/1/**//*$end*/",
+		"-- $arg:a\nSELECT * FROM t WHERE /*$optional:x*/a=/*$bind:a*/1/**//*$end*/",
+	} {
+		if _, err := NewAnnotMeta(src); err == nil {
+			t.Errorf("Expect error for %q\n", src)
+		}
+	}
 }
 
 /*
@@ -50,3 +344,194 @@ func TestBind(t *testing.T) {
 	}, false)
 }
 */
+
+func TestOrderBy(t *testing.T) {
+	fmt.Println("TestOrderBy")
+	meta, err := NewAnnotMeta("-- $func:F\nSELECT * FROM t ORDER BY /*$orderBy:sort allow:\"id,title\"*/ title /**/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `SELECT * FROM t ORDER BY {{ if eq .sort "id" }}id{{ else }}title{{ end }}{{ if .sortDesc }} DESC{{ end }}`
+	if meta.Text != expect {
+		t.Errorf("Unexpected text %q\n", meta.Text)
+	}
+	if arg := meta.Arg("sort"); arg == nil || arg.Type != "FSort" {
+		t.Errorf("Unexpected sort arg %#v\n", arg)
+	}
+	if arg := meta.Arg("sortDesc"); arg == nil || arg.Type != "bool" {
+		t.Errorf("Unexpected sortDesc arg %#v\n", arg)
+	}
+
+	// Default key must be allowed.
+	if _, err := NewAnnotMeta("SELECT * FROM t ORDER BY /*$orderBy:sort allow:\"id\"*/title/**/"); err == nil {
+		t.Errorf("Expect error for default key not allowed\n")
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	fmt.Println("TestPaginate")
+	meta, err := NewAnnotMeta("-- $arg:uid type:int\n-- $paginate by:\"b.fill_time,b.id\" desc\n" +
+		"SELECT * FROM blog b WHERE b.user_id=/*$bind:uid*/1/**/ OR b.id IN (SELECT 1 LIMIT 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT * FROM blog b WHERE {{ if .cursor }}(b.fill_time, b.id) < (:key0_, :key1_) AND {{ end }}" +
+		"(b.user_id=:uid OR b.id IN (SELECT 1 LIMIT 1)) ORDER BY b.fill_time DESC, b.id DESC LIMIT :limit"
+	if meta.Text != expect {
+		t.Errorf("Unexpected text %q\n", meta.Text)
+	}
+	if args := meta.RequiredArgs(); len(args) != 3 || args[1].Name != "cursor" || args[2].Name != "limit" {
+		t.Errorf("Unexpected required args %v\n", args)
+	}
+	if arg := meta.Arg("key1_"); arg == nil || !arg.Internal {
+		t.Errorf("Unexpected key arg %#v\n", arg)
+	}
+
+	// No WHERE.
+	testOptional(t, "-- $paginate by:id\nSELECT * FROM t", []string{
+		"SELECT * FROM t ORDER BY id LIMIT 1",
+		"SELECT * FROM t WHERE (id) > (NULL) ORDER BY id LIMIT 1",
+	})
+
+	// WHERE is kept only if some optional predicate is included.
+	testOptional(t, "-- $arg:a\n-- $paginate by:id\nSELECT * FROM t WHERE /*$optional:a*/a=/*$bind:a*/1/**//*$end*/", []string{
+		"SELECT * FROM t  ORDER BY id LIMIT 1",
+		"SELECT * FROM t WHERE (a=1) ORDER BY id LIMIT 1",
+		"SELECT * FROM t WHERE (id) > (NULL) ORDER BY id LIMIT 1",
+		"SELECT * FROM t WHERE (id) > (NULL) AND (a=1) ORDER BY id LIMIT 1",
+	})
+
+	for _, src := range []string{
+		"-- $paginate by:id\nSELECT * FROM t ORDER BY id",
+		"-- $paginate by:id\nSELECT * FROM t GROUP BY id",
+		"-- $paginate by:id\n-- $paginate by:id\nSELECT * FROM t",
+		"-- $arg:limit type:int\n-- $paginate by:id\nSELECT * FROM t WHERE id>/*$bind:limit*/1/**/",
+	} {
+		if _, err := NewAnnotMeta(src); err == nil {
+			t.Errorf("Expect error for %q\n", src)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	fmt.Println("TestPage")
+	meta, err := NewAnnotMeta("-- $arg:uid type:int\n-- $page\n" +
+		"SELECT b.*, u.nick FROM blog b JOIN user u ON b.user_id=u.id WHERE b.user_id=/*$bind:uid*/1/**/ ORDER BY b.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Text != "SELECT b.*, u.nick FROM blog b JOIN user u ON b.user_id=u.id WHERE b.user_id=:uid ORDER BY b.id LIMIT :limit OFFSET :offset" {
+		t.Errorf("Unexpected text %q\n", meta.Text)
+	}
+	if meta.CountMeta == nil || meta.CountMeta.Text != "SELECT COUNT(*) FROM (SELECT 1 FROM blog b JOIN user u ON b.user_id=u.id WHERE b.user_id=:uid) AS page_" ||
+		meta.CountMeta.SampleText != "SELECT COUNT(*) FROM (SELECT 1 FROM blog b JOIN user u ON b.user_id=u.id WHERE b.user_id=1) AS page_" {
+		t.Errorf("Unexpected count meta %#v\n", meta.CountMeta)
+	}
+	if args := meta.RequiredArgs(); len(args) != 3 || args[1].Name != "limit" || args[2].Name != "offset" {
+		t.Errorf("Unexpected required args %v\n", args)
+	}
+
+	// Select list is kept if it matters.
+	meta, err = NewAnnotMeta("-- $page\nSELECT DISTINCT nick FROM user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.CountMeta.Text != "SELECT COUNT(*) FROM (SELECT DISTINCT nick FROM user) AS page_" {
+		t.Errorf("Unexpected count text %q\n", meta.CountMeta.Text)
+	}
+
+	for _, src := range []string{
+		"-- $page\nSELECT * FROM t LIMIT 10",
+		"-- $page\n-- $paginate by:id\nSELECT * FROM t",
+		"-- $arg:a type:bool\n-- $page\nSELECT * FROM t /*$${{ if .a }}*/ORDER BY id/*$${{ end }}*/",
+		"-- $page\nUPDATE t SET a=1",
+	} {
+		if _, err := NewAnnotMeta(src); err == nil {
+			t.Errorf("Expect error for %q\n", src)
+		}
+	}
+}
+
+func TestFragments(t *testing.T) {
+	fmt.Println("TestFragments")
+	f := NewFragments()
+	src, err := f.Extract("a.sql", "-- $fragment:visible\n"+
+		"b.deleted=0 -- not deleted\n"+
+		"AND b.tenant_id=/*$bind:tenant*/1/**/ AND /*$use:own*/\n"+
+		"-- $end\n"+
+		"/*$fragment:own*/b.user_id=/*$bind:uid*/1/**//*$end*/\n"+
+		"SELECT * FROM blog b\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != "\n\n\n\n\nSELECT * FROM blog b\n" {
+		t.Errorf("Unexpected extracted source %q\n", src)
+	}
+	if fragment := f.fragments["visible"]; fragment == nil || fragment.Pos != "a.sql:1" ||
+		fragment.Text != "b.deleted=0  AND b.tenant_id=/*$bind:tenant*/1/**/ AND /*$use:own*/" {
+		t.Errorf("Unexpected fragment %#v\n", fragment)
+	}
+
+	// Line numbers are kept after expanding.
+	src, err = f.Expand("b.sql", "-- $arg:tenant type:int\n-- $arg:uid type:int\nSELECT * FROM blog b WHERE\n-- $use:visible\nAND 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(src, "\n") != 4 {
+		t.Errorf("Line numbers are not kept %q\n", src)
+	}
+	meta, err := NewAnnotMeta(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Text != "SELECT * FROM blog b WHERE\n b.deleted=0  AND b.tenant_id=:tenant AND  b.user_id=:uid  \nAND 1" {
+		t.Errorf("Unexpected text %q\n", meta.Text)
+	}
+
+	// Errors.
+	if _, err := f.Extract("c.sql", "/*$fragment:own*/1/*$end*/"); err == nil {
+		t.Errorf("Expect error for duplicated fragment\n")
+	}
+	if _, err := f.Extract("c.sql", "/*$fragment:x*/1"); err == nil {
+		t.Errorf("Expect error for fragment without $end\n")
+	}
+	if _, err := f.Expand("c.sql", "SELECT /*$use:unknown*/"); err == nil {
+		t.Errorf("Expect error for unknown fragment\n")
+	}
+	if _, err := f.Extract("c.sql", "/*$fragment:x*//*$use:y*//*$end*/ /*$fragment:y*//*$use:x*//*$end*/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Expand("c.sql", "\nSELECT /*$use:x*/"); err == nil || err.Error() != "use: cycle of fragments x -> y -> x (fragment y at c.sql:1)" {
+		t.Errorf("Unexpected cycle error %v\n", err)
+	}
+	if _, err := NewAnnotMeta("SELECT /*$use:own*/"); err == nil {
+		t.Errorf("Expect error for unexpanded fragment\n")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	fmt.Println("TestBatch")
+	meta, err := NewAnnotMeta("-- $arg:blogId type:int\n-- $arg:names type:[]string\n-- $batch:names\n" +
+		"INSERT INTO tag (blog_id, name) VALUES (/*$bind:blogId*/1/**/, CONCAT('(', /*$bind:names*/'a'/**/, ')')) " +
+		"ON DUPLICATE KEY UPDATE name=VALUES(name)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := meta.Batch; b.Prefix != "INSERT INTO tag (blog_id, name) VALUES " ||
+		b.Row != "(:blogId, CONCAT('(', :names, ')'))" || b.Suffix != " ON DUPLICATE KEY UPDATE name=VALUES(name)" {
+		t.Errorf("Unexpected batch %#v\n", b)
+	}
+
+	for _, src := range []string{
+		"-- $arg:names type:[]string\n-- $batch:names\nINSERT INTO tag (name) SELECT /*$bind:names*/'a'/**/",
+		"-- $arg:names type:[]string\n-- $batch:names\nINSERT INTO tag (name) VALUES (/*$bind:names*/'a'/**/), ('b')",
+		"-- $arg:names type:string\n-- $batch:names\nINSERT INTO tag (name) VALUES (/*$bind:names*/'a'/**/)",
+		"-- $arg:names type:[]string\n-- $batch:names,ids\nINSERT INTO tag (name) VALUES (/*$bind:names*/'a'/**/)",
+		"-- $arg:names type:[]string\n-- $batch:names\nINSERT INTO tag (name) VALUES ('a') ON DUPLICATE KEY UPDATE name=/*$bind:names*/'a'/**/",
+		"-- $arg:names type:[]string\n-- $arg:a type:bool\n-- $batch:names\nINSERT INTO tag (name) VALUES (/*$bind:names*/'a'/**/) /*$${{ if .a }}*//*$${{ end }}*/",
+	} {
+		if _, err := NewAnnotMeta(src); err == nil {
+			t.Errorf("Expect error for %q\n", src)
+		}
+	}
+}
@@ -3,7 +3,10 @@ package annot
 import (
 	"fmt"
 	"github.com/huangjunwen/JustSQL/utils"
+	"regexp"
 	"strings"
+	"text/template"
+	"text/template/parse"
 )
 
 // Global settings.
@@ -58,8 +61,16 @@ type ReturnStyle string
 const (
 	ReturnUnknown = ReturnStyle("")
 	// The following are used by SELECT
-	ReturnMany = ReturnStyle("many")
-	ReturnOne  = ReturnStyle("one")
+	ReturnMany   = ReturnStyle("many")
+	ReturnOne    = ReturnStyle("one")
+	ReturnScalar = ReturnStyle("scalar")
+	ReturnColumn = ReturnStyle("column")
+	ReturnEach   = ReturnStyle("each")
+	ReturnMap    = ReturnStyle("map")
+	// The following are used by INSERT/UPDATE/DELETE
+	ReturnRowsAffected = ReturnStyle("rowsAffected")
+	ReturnExec         = ReturnStyle("exec")
+	ReturnLastInsertId = ReturnStyle("lastInsertId")
 )
 
 // FuncAnnot declares a wrapper function for a SQL.
@@ -69,6 +80,20 @@ type FuncAnnot struct {
 
 	// Return style.
 	ReturnStyle
+
+	// Result column used as map key ("col" or "table.col") for 'map' return style.
+	Key string
+
+	// Map key to a list of results instead of a single one for 'map' return style.
+	Multi bool
+
+	// Return table struct directly instead of result struct if the query selects
+	// exactly one table's wildcard.
+	Unwrap bool
+
+	// Signature style: "params" (a single params struct) or "positional", "" to use
+	// global setting.
+	Signature string
 }
 
 func (a *FuncAnnot) SetPrimary(val string) error {
@@ -83,21 +108,56 @@ func (a *FuncAnnot) SetPrimary(val string) error {
 }
 
 func (a *FuncAnnot) Set(key, val string) error {
-	if key == "" {
-		return nil
-	}
-	if key == "return" {
-		switch val {
-		case "many":
-			a.ReturnStyle = ReturnMany
-		case "one":
-			a.ReturnStyle = ReturnOne
+	switch key {
+	default:
+		return fmt.Errorf("func: unknown option %+q", key)
+	case "return":
+		switch ReturnStyle(val) {
+		case ReturnMany, ReturnOne, ReturnScalar, ReturnColumn, ReturnEach, ReturnMap,
+			ReturnRowsAffected, ReturnExec, ReturnLastInsertId:
+			a.ReturnStyle = ReturnStyle(val)
 		default:
 			return fmt.Errorf("func: unknwon return type %+q", val)
 		}
-		return nil
+	case "key":
+		if val == "" {
+			return fmt.Errorf("func: missing key column")
+		}
+		a.Key = val
+	case "multi":
+		switch val {
+		case "", "true":
+			a.Multi = true
+		case "false":
+			a.Multi = false
+		default:
+			return fmt.Errorf("func: expect true/false for multi but got %+q", val)
+		}
+	case "signature":
+		switch val {
+		case "params", "positional":
+			a.Signature = val
+		default:
+			return fmt.Errorf("func: expect params/positional for signature but got %+q", val)
+		}
+	case "unwrap":
+		switch val {
+		case "", "true":
+			a.Unwrap = true
+		case "false":
+			a.Unwrap = false
+		default:
+			return fmt.Errorf("func: expect true/false for unwrap but got %+q", val)
+		}
+	case "":
+		if a.ReturnStyle == ReturnMap && a.Key == "" {
+			return fmt.Errorf("func: 'map' return style requires 'key' option")
+		}
+		if a.ReturnStyle != ReturnMap && (a.Key != "" || a.Multi) {
+			return fmt.Errorf("func: 'key' and 'multi' options are only for 'map' return style")
+		}
 	}
-	return fmt.Errorf("func: unknown option %+q", key)
+	return nil
 }
 
 // ArgAnnot declares a function argument (maybe used in parameter binding).
@@ -107,6 +167,17 @@ type ArgAnnot struct {
 
 	// Type of the argument.
 	Type string
+
+	// Optional argument is passed in a options struct and can be omitted.
+	Optional bool
+
+	// Go expression used when the optional argument is omitted (zero value).
+	// Implies Optional.
+	Default string
+
+	// Internal argument is a local variable of wrapper function instead of a
+	// parameter (e.g. key values decoded from pagination cursor).
+	Internal bool
 }
 
 func (a *ArgAnnot) SetPrimary(val string) error {
@@ -126,12 +197,33 @@ func (a *ArgAnnot) Set(key, val string) error {
 		return fmt.Errorf("arg: unknown option %+q", key)
 	case "type":
 		a.Type = val
+	case "optional":
+		switch val {
+		case "", "true":
+			a.Optional = true
+		case "false":
+			a.Optional = false
+		default:
+			return fmt.Errorf("arg: expect true/false for optional but got %+q", val)
+		}
+	case "default":
+		if val == "" {
+			return fmt.Errorf("arg: empty default value")
+		}
+		a.Default = val
 	case "":
-		return nil
+		if a.Default != "" {
+			a.Optional = true
+		}
 	}
 	return nil
 }
 
+// FieldName returns the struct field name of the argument: "userNick" -> "UserNick".
+func (a *ArgAnnot) FieldName() string {
+	return utils.PascalCase(utils.SnakeCase(a.Name))
+}
+
 // BindAnnot declare a query binding.
 type BindAnnot struct {
 	// Bind arg name.
@@ -184,106 +276,1477 @@ func (a *EnvAnnot) Set(key, val string) error {
 	return nil
 }
 
-// Regist Annotations.
-func init() {
-	RegistAnnot((*SettingAnnot)(nil), "setting")
-	RegistAnnot((*FuncAnnot)(nil), "func")
-	RegistAnnot((*ArgAnnot)(nil), "arg", "param")
-	RegistAnnot((*BindAnnot)(nil), "bind")
-	RegistAnnot((*EnvAnnot)(nil), "env")
+// TypeAnnot declares a custom Go type for a column (used in DDL column comment).
+type TypeAnnot struct {
+	// Type spec: [pkgPath.]type
+	Type string
 }
 
-// AnnotMeta contains meta information of annotations.
-type AnnotMeta struct {
-	// Source query text.
-	SrcText string
+func (a *TypeAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("type: missing type")
+	}
+	a.Type = val
+	return nil
+}
 
-	// Processed query text (e.g. comments stripped and annotations processed).
-	Text string
+func (a *TypeAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("type: unknown option %+q", key)
+	case "":
+		return nil
+	}
+	return nil
+}
 
-	// Comments (and annotations) in SrcQuery.
-	Comments []Comment
+// NameAnnot declares a custom Go name for a column (used in DDL column comment).
+type NameAnnot struct {
+	Name string
+}
 
-	// Function name (from FuncAnnot).
-	FuncName string
+func (a *NameAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("name: missing name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("name: %+q is not a valid identifier", val)
+	}
+	a.Name = val
+	return nil
+}
 
-	// Function arguments (from ArgAnnot).
-	Args []*ArgAnnot
+func (a *NameAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("name: unknown option %+q", key)
+	case "":
+		return nil
+	}
+	return nil
+}
 
-	// Return style.
-	ReturnStyle
+// StructAnnot declares a custom Go struct name for a table (used in DDL table comment).
+type StructAnnot struct {
+	Name string
+}
 
-	// Arbitrary key/values.
-	Envs map[string]string
+func (a *StructAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("struct: missing struct name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("struct: %+q is not a valid identifier", val)
+	}
+	a.Name = val
+	return nil
 }
 
-var noNameCnt int = 0
+func (a *StructAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("struct: unknown option %+q", key)
+	case "":
+		return nil
+	}
+	return nil
+}
 
-// NewAnnotMeta gather wrapper meta from source query's comments (annotations).
-func NewAnnotMeta(src string) (*AnnotMeta, error) {
+// SkipAnnot declares that no code should be generated for a table (used in DDL
+// table comment).
+type SkipAnnot struct{}
 
-	ret := &AnnotMeta{
-		SrcText: src,
-		Args:    make([]*ArgAnnot, 0),
-		Envs:    make(map[string]string),
+func (a *SkipAnnot) SetPrimary(val string) error {
+	if val != "" {
+		return fmt.Errorf("skip: expect no primary value but got %+q", val)
 	}
+	return nil
+}
 
-	comments, err := ScanComment(src)
-	if err != nil {
-		return nil, err
+func (a *SkipAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("skip: unknown option %+q", key)
+	case "":
+		return nil
 	}
-	ret.Comments = comments
+	return nil
+}
 
-	parts := []string{}
-	offset := 0
-	for i := 0; i < len(comments); i++ {
-		comment := comments[i]
+// ReadOnlyAnnot declares that a table is read-only: no Insert/Update/Delete methods
+// will be generated (used in DDL table comment).
+type ReadOnlyAnnot struct{}
 
-		// Append text before the comment.
-		parts = append(parts, src[offset:comment.Offset])
+func (a *ReadOnlyAnnot) SetPrimary(val string) error {
+	if val != "" {
+		return fmt.Errorf("readonly: expect no primary value but got %+q", val)
+	}
+	return nil
+}
 
-		// For different annotations.
-		switch a := comment.Annot.(type) {
-		case *SubsAnnot:
-			parts = append(parts, a.Content)
+func (a *ReadOnlyAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("readonly: unknown option %+q", key)
+	case "":
+		return nil
+	}
+	return nil
+}
 
-		case *FuncAnnot:
-			ret.FuncName = a.Name
-			ret.ReturnStyle = a.ReturnStyle
+// FinderAnnot declares an extra finder function for a table which queries the table
+// by some columns (used in DDL table comment). Example:
+//   finder:user_id,title name:ByAuthorAndTitle return:one
+type FinderAnnot struct {
+	// Column names.
+	Columns []string
 
-		case *ArgAnnot:
-			ret.Args = append(ret.Args, a)
+	// Finder name (without struct name prefix), default to "By" + column names.
+	Name string
 
-		case *BindAnnot:
-			// Find the next comment.
-			i += 1
-			if i >= len(comments) {
-				return nil, fmt.Errorf("bind: %q missing enclosure", a.Name)
-			}
-			parts = append(parts, BindNamePrefix+a.Name)
-			comment = comments[i]
+	// Return style: ReturnMany (default) or ReturnOne.
+	ReturnStyle
+}
 
-		case *EnvAnnot:
-			for k, v := range a.Env {
-				ret.Envs[k] = v
+func (a *FinderAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("finder: missing columns")
+	}
+	for _, col := range strings.Split(val, ",") {
+		col = strings.TrimSpace(col)
+		if !utils.IsIdent(col) {
+			return fmt.Errorf("finder: column %+q is not a valid identifier", col)
+		}
+		a.Columns = append(a.Columns, strings.ToLower(col))
+	}
+	return nil
+}
+
+func (a *FinderAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("finder: unknown option %+q", key)
+	case "name":
+		if !utils.IsIdent(val) {
+			return fmt.Errorf("finder: name %+q is not a valid identifier", val)
+		}
+		a.Name = val
+	case "return":
+		switch val {
+		case "many":
+			a.ReturnStyle = ReturnMany
+		case "one":
+			a.ReturnStyle = ReturnOne
+		default:
+			return fmt.Errorf("finder: unknwon return type %+q", val)
+		}
+	case "":
+		if a.ReturnStyle == ReturnUnknown {
+			a.ReturnStyle = ReturnMany
+		}
+		if a.Name == "" {
+			parts := []string{}
+			for _, col := range a.Columns {
+				parts = append(parts, utils.PascalCase(col))
 			}
+			a.Name = "By" + strings.Join(parts, "And")
+		}
+	}
+	return nil
+}
+
+// TagsAnnot declares extra struct tags for generated structs (used in DDL table
+// comment or before a DML statement). Example: "tags:json,yaml naming:camel omitempty".
+type TagsAnnot struct {
+	// Tag names.
+	Names []string
+
+	// Naming convention of tag values: "snake" (default), "camel" or "original".
+	Naming string
+
+	// Append ",omitempty" for nullable fields.
+	OmitEmpty bool
+}
+
+func (a *TagsAnnot) SetPrimary(val string) error {
+	a.Names = make([]string, 0)
+	if val == "" {
+		return nil
+	}
+	for _, name := range strings.Split(val, ",") {
+		name = strings.TrimSpace(name)
+		if !utils.IsIdent(name) {
+			return fmt.Errorf("tags: tag name %+q is not a valid identifier", name)
+		}
+		a.Names = append(a.Names, name)
+	}
+	return nil
+}
 
+func (a *TagsAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("tags: unknown option %+q", key)
+	case "naming":
+		switch val {
+		case "snake", "camel", "original":
+			a.Naming = val
 		default:
+			return fmt.Errorf("tags: unknown naming %+q", val)
+		}
+	case "omitempty":
+		switch val {
+		case "", "true":
+			a.OmitEmpty = true
+		case "false":
+			a.OmitEmpty = false
+		default:
+			return fmt.Errorf("tags: expect true/false for omitempty but got %+q", val)
+		}
+	case "":
+		if a.Naming == "" {
+			a.Naming = "snake"
 		}
+	}
+	return nil
+}
 
-		offset = comment.Offset + comment.Length
+// ResultAnnot names the result struct of a SELECT so that it can be shared by
+// queries having the same result fields.
+type ResultAnnot struct {
+	Name string
+}
+
+func (a *ResultAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("result: missing result struct name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("result: result struct name %+q is not a valid identifier", val)
 	}
+	a.Name = val
+	return nil
+}
 
-	parts = append(parts, src[offset:])
-	ret.Text = strings.Trim(strings.Join(parts, ""), " \t\n\r;")
+func (a *ResultAnnot) Set(key, val string) error {
+	if key == "" {
+		return nil
+	}
+	return fmt.Errorf("result: unknown option %+q", key)
+}
 
-	if ret.FuncName == "" {
-		noNameCnt += 1
-		ret.FuncName = fmt.Sprintf("NoName%d", noNameCnt)
+// TxAnnot groups consecutive INSERT/UPDATE/DELETE statements (in a file) having
+// the same name into a transaction function: "$tx:CreateBlog".
+type TxAnnot struct {
+	Name string
+}
+
+func (a *TxAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("tx: missing transaction function name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("tx: transaction function name %+q is not a valid identifier", val)
+	}
+	a.Name = val
+	return nil
+}
+
+func (a *TxAnnot) Set(key, val string) error {
+	if key == "" {
+		return nil
 	}
+	return fmt.Errorf("tx: unknown option %+q", key)
+}
 
-	return ret, nil
+// BatchAnnot declares a multi-row INSERT: "$batch:names,ages". The VALUES row is
+// repeated for each element of the slice args.
+type BatchAnnot struct {
+	// Slice args bound element by element in the VALUES row.
+	Args []string
+
+	// Query text before the VALUES row, of the row and after it. Filled by
+	// NewAnnotMeta.
+	Prefix, Row, Suffix string
+}
 
+func (a *BatchAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("batch: missing batch args")
+	}
+	for _, name := range strings.Split(val, ",") {
+		name = strings.TrimSpace(name)
+		if !utils.IsIdent(name) {
+			return fmt.Errorf("batch: arg name %+q is not a valid identifier", name)
+		}
+		a.Args = append(a.Args, name)
+	}
+	return nil
+}
+
+func (a *BatchAnnot) Set(key, val string) error {
+	if key == "" {
+		return nil
+	}
+	return fmt.Errorf("batch: unknown option %+q", key)
+}
+
+// HasArg returns true if the arg is bound element by element.
+func (a *BatchAnnot) HasArg(name string) bool {
+	for _, arg := range a.Args {
+		if arg == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupAnnot declares folding rows of a JOIN query into parent/children results:
+// "$group:u children:b,c" groups rows by primary key of table u and collects
+// rows of table b and c (de-duplicated by their primary keys) into slices.
+type GroupAnnot struct {
+	// Table ref name of parent.
+	Parent string
+
+	// Table ref names of children.
+	Children []string
+}
+
+func (a *GroupAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("group: missing parent table")
+	}
+	a.Parent = strings.ToLower(val)
+	return nil
+}
+
+func (a *GroupAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("group: unknown option %+q", key)
+	case "children":
+		for _, child := range strings.Split(val, ",") {
+			child = strings.ToLower(strings.TrimSpace(child))
+			if child == "" {
+				return fmt.Errorf("group: empty child table in %+q", val)
+			}
+			a.Children = append(a.Children, child)
+		}
+	case "":
+		if len(a.Children) == 0 {
+			return fmt.Errorf("group: missing children tables")
+		}
+	}
+	return nil
+}
+
+// OptionalAnnot starts an optional predicate ended by EndAnnot:
+// "$optional:title" includes the predicate only if arg title is not zero (nil/empty),
+// "$optional:title if:hasTitle" includes it only if bool arg hasTitle is true.
+type OptionalAnnot struct {
+	// Arg name.
+	Name string
+
+	// Companion arg deciding whether to include the predicate, "" to use the arg itself.
+	If string
+}
+
+func (a *OptionalAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("optional: missing arg name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("optional: arg name %+q is not a valid identifier", val)
+	}
+	a.Name = val
+	return nil
+}
+
+func (a *OptionalAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("optional: unknown option %+q", key)
+	case "if":
+		if !utils.IsIdent(val) {
+			return fmt.Errorf("optional: arg name %+q is not a valid identifier", val)
+		}
+		a.If = val
+	case "":
+	}
+	return nil
+}
+
+// Template condition to include the predicate.
+func (a *OptionalAnnot) cond() string {
+	if a.If != "" {
+		return "." + a.If
+	}
+	return "." + a.Name
+}
+
+// EndAnnot ends the block started by OptionalAnnot.
+type EndAnnot struct{}
+
+func (a *EndAnnot) SetPrimary(val string) error {
+	if val != "" {
+		return fmt.Errorf("end: expect no primary value but got %+q", val)
+	}
+	return nil
+}
+
+func (a *EndAnnot) Set(key, val string) error {
+	if key == "" {
+		return nil
+	}
+	return fmt.Errorf("end: unknown option %+q", key)
+}
+
+// OrderByAnnot declares a dynamic ORDER BY key: "$orderBy:sort allow:\"created_at,title\""
+// adds arg "sort" (an enum type of allowed keys) and bool arg "sortDesc" to the
+// wrapper function. The content between the annotation and the next comment is
+// the default key (used for zero value) and is replaced by the chosen key.
+type OrderByAnnot struct {
+	// Arg name.
+	Name string
+
+	// Allowed keys: "col" or "table.col".
+	Allow []string
+
+	// Default key, filled by NewAnnotMeta.
+	Default string
+
+	// Enum type name of keys, filled by NewAnnotMeta.
+	TypeName string
+}
+
+var orderByKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+func (a *OrderByAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("orderBy: missing arg name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("orderBy: arg name %+q is not a valid identifier", val)
+	}
+	a.Name = val
+	return nil
+}
+
+func (a *OrderByAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("orderBy: unknown option %+q", key)
+	case "allow":
+		for _, k := range strings.Split(val, ",") {
+			k = strings.TrimSpace(k)
+			if !orderByKeyRe.MatchString(k) {
+				return fmt.Errorf("orderBy: key %+q is not \"col\" or \"table.col\"", k)
+			}
+			a.Allow = append(a.Allow, k)
+		}
+	case "":
+		if len(a.Allow) == 0 {
+			return fmt.Errorf("orderBy: missing allowed keys")
+		}
+		keyNames := make(map[string]string)
+		for _, key := range a.Allow {
+			if prev, ok := keyNames[a.KeyName(key)]; ok {
+				return fmt.Errorf("orderBy: key %+q and %+q have the same name", prev, key)
+			}
+			keyNames[a.KeyName(key)] = key
+		}
+	}
+	return nil
+}
+
+// KeyName returns the Go name suffix of a key's constant.
+func (a *OrderByAnnot) KeyName(key string) string {
+	return utils.PascalCase(utils.SnakeCase(strings.Replace(key, ".", "_", -1)))
+}
+
+// Template choosing the key by arg value, only allowed keys are substituted.
+func (a *OrderByAnnot) template() string {
+	ret := ""
+	for _, key := range a.Allow {
+		if key == a.Default {
+			continue
+		}
+		ret += fmt.Sprintf("{{ if eq .%s %q }}%s{{ else }}", a.Name, key, key)
+	}
+	ret += a.Default
+	for _, key := range a.Allow {
+		if key != a.Default {
+			ret += "{{ end }}"
+		}
+	}
+	return ret + fmt.Sprintf("{{ if .%sDesc }} DESC{{ end }}", a.Name)
+}
+
+// PaginateAnnot declares keyset pagination: "$paginate by:\"b.fill_time,b.id\""
+// adds string arg "cursor" (empty for the first page) and int arg "limit" to the
+// wrapper function which also returns the cursor of the next page. The query
+// is sorted by the keys (descendingly if "desc") which must be result columns
+// containing a unique index.
+type PaginateAnnot struct {
+	// Key columns: "col" or "table.col".
+	By []string
+
+	// Sort descendingly.
+	Desc bool
+
+	// Internal args holding key values decoded from cursor, filled by NewAnnotMeta.
+	KeyArgs []*ArgAnnot
+}
+
+func (a *PaginateAnnot) SetPrimary(val string) error {
+	if val != "" {
+		return fmt.Errorf("paginate: expect no primary value but got %+q", val)
+	}
+	return nil
+}
+
+func (a *PaginateAnnot) Set(key, val string) error {
+	switch key {
+	default:
+		return fmt.Errorf("paginate: unknown option %+q", key)
+	case "by":
+		for _, k := range strings.Split(val, ",") {
+			k = strings.TrimSpace(k)
+			if !orderByKeyRe.MatchString(k) {
+				return fmt.Errorf("paginate: key %+q is not \"col\" or \"table.col\"", k)
+			}
+			a.By = append(a.By, k)
+		}
+	case "desc":
+		switch val {
+		case "", "true":
+			a.Desc = true
+		case "false":
+			a.Desc = false
+		default:
+			return fmt.Errorf("paginate: expect true/false for desc but got %+q", val)
+		}
+	case "":
+		if len(a.By) == 0 {
+			return fmt.Errorf("paginate: missing keys")
+		}
+	}
+	return nil
+}
+
+// PageAnnot declares offset pagination: "$page" adds int args "limit" and "offset"
+// to the wrapper function which also returns the total number of rows counted by
+// a derived "SELECT COUNT(*) FROM (...)" query.
+type PageAnnot struct{}
+
+func (a *PageAnnot) SetPrimary(val string) error {
+	if val != "" {
+		return fmt.Errorf("page: expect no primary value but got %+q", val)
+	}
+	return nil
+}
+
+func (a *PageAnnot) Set(key, val string) error {
+	if key == "" {
+		return nil
+	}
+	return fmt.Errorf("page: unknown option %+q", key)
+}
+
+// FragmentAnnot starts a fragment declaration ended by EndAnnot: "$fragment:visible".
+// See Fragments.
+type FragmentAnnot struct {
+	// Fragment name.
+	Name string
+}
+
+func (a *FragmentAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("fragment: missing fragment name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("fragment: fragment name %+q is not a valid identifier", val)
+	}
+	a.Name = val
+	return nil
+}
+
+func (a *FragmentAnnot) Set(key, val string) error {
+	if key == "" {
+		return nil
+	}
+	return fmt.Errorf("fragment: unknown option %+q", key)
+}
+
+// UseAnnot references a fragment: "$use:visible". See Fragments.
+type UseAnnot struct {
+	// Fragment name.
+	Name string
+}
+
+func (a *UseAnnot) SetPrimary(val string) error {
+	if val == "" {
+		return fmt.Errorf("use: missing fragment name")
+	}
+	if !utils.IsIdent(val) {
+		return fmt.Errorf("use: fragment name %+q is not a valid identifier", val)
+	}
+	a.Name = val
+	return nil
+}
+
+func (a *UseAnnot) Set(key, val string) error {
+	if key == "" {
+		return nil
+	}
+	return fmt.Errorf("use: unknown option %+q", key)
+}
+
+// Regist Annotations.
+func init() {
+	RegistAnnot((*SettingAnnot)(nil), "setting")
+	RegistAnnot((*FuncAnnot)(nil), "func")
+	RegistAnnot((*ArgAnnot)(nil), "arg", "param")
+	RegistAnnot((*BindAnnot)(nil), "bind")
+	RegistAnnot((*EnvAnnot)(nil), "env")
+	RegistAnnot((*TypeAnnot)(nil), "type")
+	RegistAnnot((*NameAnnot)(nil), "name")
+	RegistAnnot((*StructAnnot)(nil), "struct")
+	RegistAnnot((*SkipAnnot)(nil), "skip")
+	RegistAnnot((*ReadOnlyAnnot)(nil), "readonly")
+	RegistAnnot((*FinderAnnot)(nil), "finder")
+	RegistAnnot((*TagsAnnot)(nil), "tags")
+	RegistAnnot((*GroupAnnot)(nil), "group")
+	RegistAnnot((*ResultAnnot)(nil), "result")
+	RegistAnnot((*OptionalAnnot)(nil), "optional")
+	RegistAnnot((*EndAnnot)(nil), "end")
+	RegistAnnot((*OrderByAnnot)(nil), "orderBy")
+	RegistAnnot((*PaginateAnnot)(nil), "paginate")
+	RegistAnnot((*PageAnnot)(nil), "page")
+	RegistAnnot((*FragmentAnnot)(nil), "fragment")
+	RegistAnnot((*UseAnnot)(nil), "use")
+	RegistAnnot((*TxAnnot)(nil), "tx")
+	RegistAnnot((*BatchAnnot)(nil), "batch")
+}
+
+// Binding contains the position of a query binding's placeholder content (the part
+// between the bind annotation and the next comment) in source text.
+type Binding struct {
+	// Bind arg name.
+	Name string
+
+	// Offset and length of the placeholder content.
+	Offset, Length int
+}
+
+// AnnotMeta contains meta information of annotations.
+type AnnotMeta struct {
+	// Source query text.
+	SrcText string
+
+	// Processed query text (e.g. comments stripped and annotations processed).
+	Text string
+
+	// Like Text but bind placeholder contents are kept instead of replaced by
+	// named bindings so that it can be compiled.
+	SampleText string
+
+	// Comments (and annotations) in SrcQuery.
+	Comments []Comment
+
+	// Function name (from FuncAnnot).
+	FuncName string
+
+	// Function arguments (from ArgAnnot).
+	Args []*ArgAnnot
+
+	// Query bindings (from BindAnnot) in order.
+	Bindings []*Binding
+
+	// Return style.
+	ReturnStyle
+
+	// Map key and multi option for 'map' return style.
+	MapKey   string
+	MapMulti bool
+
+	// Return table struct directly.
+	Unwrap bool
+
+	// Signature style of wrapper function ("params"/"positional"), "" if not declared.
+	Signature string
+
+	// Extra struct tags for result struct (from TagsAnnot), nil if not declared.
+	Tags *TagsAnnot
+
+	// Parent/children grouping (from GroupAnnot), nil if not declared.
+	Group *GroupAnnot
+
+	// Shared result struct name (from ResultAnnot), "" if not declared.
+	ResultName string
+
+	// Dynamic ORDER BY keys (from OrderByAnnot).
+	OrderBys []*OrderByAnnot
+
+	// Optional predicates (from OptionalAnnot).
+	Optionals []*OptionalAnnot
+
+	// Keyset pagination (from PaginateAnnot), nil if not declared.
+	Paginate *PaginateAnnot
+
+	// Offset pagination (from PageAnnot): the derived count query sharing args,
+	// nil if not declared.
+	CountMeta *AnnotMeta
+
+	// Transaction function name (from TxAnnot), "" if not declared.
+	TxName string
+
+	// Multi-row INSERT (from BatchAnnot), nil if not declared.
+	Batch *BatchAnnot
+
+	// Arbitrary key/values.
+	Envs map[string]string
+}
+
+var noNameCnt int = 0
+
+// NewAnnotMeta gather wrapper meta from source query's comments (annotations).
+func NewAnnotMeta(src string) (*AnnotMeta, error) {
+
+	ret := &AnnotMeta{
+		SrcText:  src,
+		Args:     make([]*ArgAnnot, 0),
+		Bindings: make([]*Binding, 0),
+		Envs:     make(map[string]string),
+	}
+
+	comments, err := ScanComment(src)
+	if err != nil {
+		return nil, err
+	}
+	ret.Comments = comments
+
+	// parts and sampleParts are always appended together so that they have the
+	// same indices.
+	parts := []string{}
+	sampleParts := []string{}
+	optionals := []*optionalBlock{}
+	var optional *optionalBlock
+	page := false
+	offset := 0
+	for i := 0; i < len(comments); i++ {
+		comment := comments[i]
+
+		// Append text before the comment.
+		parts = append(parts, src[offset:comment.Offset])
+		sampleParts = append(sampleParts, src[offset:comment.Offset])
+
+		// For different annotations.
+		switch a := comment.Annot.(type) {
+		case *SubsAnnot:
+			parts = append(parts, a.Content)
+			sampleParts = append(sampleParts, a.Content)
+
+		case *FuncAnnot:
+			ret.FuncName = a.Name
+			ret.ReturnStyle = a.ReturnStyle
+			ret.MapKey = a.Key
+			ret.MapMulti = a.Multi
+			ret.Unwrap = a.Unwrap
+			ret.Signature = a.Signature
+
+		case *ArgAnnot:
+			ret.Args = append(ret.Args, a)
+
+		case *TagsAnnot:
+			ret.Tags = a
+
+		case *GroupAnnot:
+			ret.Group = a
+
+		case *ResultAnnot:
+			ret.ResultName = a.Name
+
+		case *TxAnnot:
+			if ret.TxName != "" {
+				return nil, fmt.Errorf("tx: duplicate \"$tx\"")
+			}
+			ret.TxName = a.Name
+
+		case *BatchAnnot:
+			if ret.Batch != nil {
+				return nil, fmt.Errorf("batch: duplicate \"$batch\"")
+			}
+			ret.Batch = a
+
+		case *PaginateAnnot:
+			if ret.Paginate != nil {
+				return nil, fmt.Errorf("paginate: duplicate \"$paginate\"")
+			}
+			ret.Paginate = a
+
+		case *PageAnnot:
+			page = true
+
+		case *FragmentAnnot:
+			return nil, fmt.Errorf("fragment: %q should be declared outside statements", a.Name)
+
+		case *UseAnnot:
+			return nil, fmt.Errorf("use: fragment %q is not expanded", a.Name)
+
+		case *BindAnnot:
+			// Find the next comment.
+			i += 1
+			if i >= len(comments) {
+				return nil, fmt.Errorf("bind: %q missing enclosure", a.Name)
+			}
+			parts = append(parts, BindNamePrefix+a.Name)
+			sampleParts = append(sampleParts, src[comment.Offset+comment.Length:comments[i].Offset])
+			ret.Bindings = append(ret.Bindings, &Binding{
+				Name:   a.Name,
+				Offset: comment.Offset + comment.Length,
+				Length: comments[i].Offset - comment.Offset - comment.Length,
+			})
+			comment = comments[i]
+
+		case *EnvAnnot:
+			for k, v := range a.Env {
+				ret.Envs[k] = v
+			}
+
+		case *OrderByAnnot:
+			// Find the next comment.
+			i += 1
+			if i >= len(comments) {
+				return nil, fmt.Errorf("orderBy: %q missing enclosure", a.Name)
+			}
+			a.Default = strings.TrimSpace(src[comment.Offset+comment.Length : comments[i].Offset])
+			found := false
+			for _, key := range a.Allow {
+				found = found || key == a.Default
+			}
+			if !found {
+				return nil, fmt.Errorf("orderBy: default key %+q is not allowed", a.Default)
+			}
+			parts = append(parts, a.template())
+			sampleParts = append(sampleParts, a.template())
+			ret.OrderBys = append(ret.OrderBys, a)
+			ret.Args = append(ret.Args, &ArgAnnot{
+				Name: a.Name,
+			}, &ArgAnnot{
+				Name: a.Name + "Desc",
+				Type: "bool",
+			})
+			comment = comments[i]
+
+		case *OptionalAnnot:
+			if optional != nil {
+				return nil, fmt.Errorf("optional: %q is not ended by \"$end\"", optional.annot.Name)
+			}
+			optional = &optionalBlock{
+				annot: a,
+				start: len(parts),
+			}
+			parts = append(parts, "")
+			sampleParts = append(sampleParts, "")
+
+		case *EndAnnot:
+			if optional == nil {
+				return nil, fmt.Errorf("end: missing \"$optional\" before")
+			}
+			optional.end = len(parts)
+			optionals = append(optionals, optional)
+			ret.Optionals = append(ret.Optionals, optional.annot)
+			optional = nil
+			parts = append(parts, "")
+			sampleParts = append(sampleParts, "")
+
+		default:
+		}
+
+		offset = comment.Offset + comment.Length
+	}
+	if optional != nil {
+		return nil, fmt.Errorf("optional: %q is not ended by \"$end\"", optional.annot.Name)
+	}
+
+	parts = append(parts, src[offset:])
+	sampleParts = append(sampleParts, src[offset:])
+	fixOptionals(parts, sampleParts, optionals)
+	ret.Text = strings.Trim(strings.Join(parts, ""), " \t\n\r;")
+	ret.SampleText = strings.Trim(strings.Join(sampleParts, ""), " \t\n\r;")
+
+	if p := ret.Paginate; p != nil {
+		for i := range p.By {
+			p.KeyArgs = append(p.KeyArgs, &ArgAnnot{
+				Name:     fmt.Sprintf("key%d_", i),
+				Internal: true,
+			})
+		}
+		ret.Args = append(ret.Args, &ArgAnnot{
+			Name: "cursor",
+			Type: "string",
+		}, &ArgAnnot{
+			Name: "limit",
+			Type: "int",
+		})
+		ret.Args = append(ret.Args, p.KeyArgs...)
+		if ret.Text, err = paginateText(ret.Text, p, func(name string) string {
+			return BindNamePrefix + name
+		}); err != nil {
+			return nil, err
+		}
+		if ret.SampleText, err = paginateText(ret.SampleText, p, func(name string) string {
+			if name == "limit" {
+				return "1"
+			}
+			return "NULL"
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var countText, countSampleText string
+	if page {
+		if ret.Paginate != nil {
+			return nil, fmt.Errorf("page: can't be used with \"$paginate\"")
+		}
+		if countText, err = pageCountText(ret.Text); err != nil {
+			return nil, err
+		}
+		if countSampleText, err = pageCountText(ret.SampleText); err != nil {
+			return nil, err
+		}
+		ret.Args = append(ret.Args, &ArgAnnot{
+			Name: "limit",
+			Type: "int",
+		}, &ArgAnnot{
+			Name: "offset",
+			Type: "int",
+		})
+		ret.Text += " LIMIT " + BindNamePrefix + "limit OFFSET " + BindNamePrefix + "offset"
+		ret.SampleText += " LIMIT 1 OFFSET 0"
+	}
+
+	if b := ret.Batch; b != nil {
+		if b.Prefix, b.Row, b.Suffix, err = batchText(ret.Text); err != nil {
+			return nil, err
+		}
+		for _, name := range b.Args {
+			arg := ret.Arg(name)
+			if arg == nil {
+				return nil, fmt.Errorf("batch: arg %+q is not declared by \"$arg:%s\"", name, name)
+			}
+			if arg.Type != "" && !strings.HasPrefix(arg.Type, "[]") {
+				return nil, fmt.Errorf("batch: arg %+q should be a slice", name)
+			}
+			bindRe := regexp.MustCompile(regexp.QuoteMeta(BindNamePrefix+name) + `\b`)
+			if !bindRe.MatchString(b.Row) || bindRe.MatchString(b.Prefix) || bindRe.MatchString(b.Suffix) {
+				return nil, fmt.Errorf("batch: arg %+q should be bound in the VALUES row only", name)
+			}
+		}
+	}
+
+	if ret.FuncName == "" {
+		noNameCnt += 1
+		ret.FuncName = fmt.Sprintf("NoName%d", noNameCnt)
+	}
+
+	for _, orderBy := range ret.OrderBys {
+		arg := ret.Arg(orderBy.Name)
+		orderBy.TypeName = ret.FuncName + arg.FieldName()
+		arg.Type = orderBy.TypeName
+	}
+
+	if page {
+		countMeta := *ret
+		countMeta.Text = countText
+		countMeta.SampleText = countSampleText
+		ret.CountMeta = &countMeta
+	}
+
+	if err := ret.Validate(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+
+}
+
+// Position of optional predicate markers in parts.
+type optionalBlock struct {
+	annot      *OptionalAnnot
+	start, end int
+}
+
+var (
+	// Leading AND/OR of a predicate.
+	predicateLeadRe = regexp.MustCompile(`(?i)^(\s*)(AND|OR)\b`)
+
+	// Text before the first predicate.
+	predicateListRe = regexp.MustCompile(`(?i)(\bWHERE|\bHAVING|\()\s*$`)
+)
+
+// Replace optional predicate markers with template conditions. For a run of
+// optional predicates (separated by spaces only) at the start of a predicate list
+// (after WHERE/HAVING/"("), the leading AND/OR of a predicate is kept only if
+// some predicate before it in the run is included, and the WHERE/HAVING (or the
+// leading AND/OR of the predicate following the run) is kept only if any is included.
+func fixOptionals(parts, sampleParts []string, optionals []*optionalBlock) {
+
+	blank := func(i, j int) bool {
+		for ; i < j; i++ {
+			if strings.TrimSpace(parts[i]) != "" {
+				return false
+			}
+		}
+		return true
+	}
+	anyOf := func(run []*optionalBlock) string {
+		conds := []string{}
+		for _, b := range run {
+			conds = append(conds, b.annot.cond())
+		}
+		if len(conds) == 1 {
+			return conds[0]
+		}
+		return "or " + strings.Join(conds, " ")
+	}
+	// Text parts are the same in parts and sampleParts.
+	set := func(i int, s string) {
+		parts[i] = s
+		sampleParts[i] = s
+	}
+
+	for i := 0; i < len(optionals); {
+
+		j := i + 1
+		for j < len(optionals) && blank(optionals[j-1].end+1, optionals[j].start) {
+			j += 1
+		}
+		run := optionals[i:j]
+		i = j
+
+		prev := run[0].start - 1
+		for prev >= 0 && strings.TrimSpace(parts[prev]) == "" {
+			prev -= 1
+		}
+		listStart := prev >= 0 && predicateListRe.MatchString(parts[prev])
+
+		for k, b := range run {
+			head := "{{ if " + b.annot.cond() + " }}"
+			if first := b.start + 1; listStart && first < b.end {
+				if m := predicateLeadRe.FindStringSubmatch(parts[first]); m != nil {
+					set(first, parts[first][len(m[0]):])
+					if k > 0 {
+						head += m[1] + "{{ if " + anyOf(run[:k]) + " }}" + m[2] + "{{ end }}"
+					}
+				}
+			}
+			set(b.start, head)
+			set(b.end, "{{ end }}")
+		}
+
+		if !listStart {
+			continue
+		}
+		cond := anyOf(run)
+
+		next := run[len(run)-1].end + 1
+		for next < len(parts) && strings.TrimSpace(parts[next]) == "" {
+			next += 1
+		}
+		if next < len(parts) {
+			if m := predicateLeadRe.FindStringSubmatch(parts[next]); m != nil {
+				set(next, m[1]+"{{ if "+cond+" }}"+m[2]+"{{ end }}"+parts[next][len(m[0]):])
+				continue
+			}
+		}
+
+		// No predicate is left if none is included.
+		loc := predicateListRe.FindStringSubmatchIndex(parts[prev])
+		if kw := parts[prev][loc[2]:loc[3]]; kw != "(" {
+			set(prev, parts[prev][:loc[2]]+"{{ if "+cond+" }}"+kw+"{{ end }}"+parts[prev][loc[3]:])
+		}
+	}
+
+}
+
+var (
+	// WHERE kept only if some optional predicate is included.
+	optionalWhereRe = regexp.MustCompile(`\{\{ if ([^{}]*) \}\}$`)
+)
+
+// Return upper cased words and their offsets at the top level of query text: not
+// in parentheses, quotes or template actions. Bind names are skipped.
+func topLevelWords(text string) ([]string, []int) {
+
+	isWordChar := func(c byte) bool {
+		return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	words, offsets := []string{}, []int{}
+	depth := 0
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case strings.HasPrefix(text[i:], "{{"):
+			j := strings.Index(text[i:], "}}")
+			if j < 0 {
+				return words, offsets
+			}
+			i += j + 2
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(text) && text[j] != c {
+				if text[j] == '\\' {
+					j += 1
+				}
+				j += 1
+			}
+			i = j + 1
+		case c == '(':
+			depth += 1
+			i += 1
+		case c == ')':
+			depth -= 1
+			i += 1
+		case isWordChar(c):
+			j := i
+			for j < len(text) && isWordChar(text[j]) {
+				j += 1
+			}
+			if depth == 0 && (i == 0 || text[i-1] != ':') {
+				words = append(words, strings.ToUpper(text[i:j]))
+				offsets = append(offsets, i)
+			}
+			i = j
+		default:
+			i += 1
+		}
+	}
+	return words, offsets
+
+}
+
+// Rewrite query text for keyset pagination: the original WHERE condition is
+// and-ed with "(keys) > (key values)" if cursor is not empty, then ORDER BY and
+// LIMIT are appended. bind returns the text of a binding.
+func paginateText(text string, a *PaginateAnnot, bind func(string) string) (string, error) {
+
+	where := -1
+	words, offsets := topLevelWords(text)
+	for i, word := range words {
+		switch word {
+		case "WHERE":
+			if where >= 0 {
+				return "", fmt.Errorf("paginate: more than one WHERE in query")
+			}
+			where = offsets[i]
+		case "GROUP", "HAVING", "ORDER", "LIMIT", "UNION", "FOR", "LOCK", "INTO":
+			return "", fmt.Errorf("paginate: %s is not allowed in paginated query", word)
+		}
+	}
+
+	keys, values, orders := []string{}, []string{}, []string{}
+	for i, key := range a.By {
+		keys = append(keys, key)
+		values = append(values, bind(a.KeyArgs[i].Name))
+		if a.Desc {
+			key += " DESC"
+		}
+		orders = append(orders, key)
+	}
+	op := ">"
+	if a.Desc {
+		op = "<"
+	}
+	pred := fmt.Sprintf("(%s) %s (%s)", strings.Join(keys, ", "), op, strings.Join(values, ", "))
+
+	ret := ""
+	switch {
+	case where < 0:
+		ret = text + "{{ if .cursor }} WHERE " + pred + "{{ end }}"
+
+	case optionalWhereRe.MatchString(text[:where]) && strings.HasPrefix(text[where+5:], "{{ end }}"):
+		// WHERE is kept only if some optional predicate is included.
+		loc := optionalWhereRe.FindStringSubmatchIndex(text[:where])
+		cond := text[loc[2]:loc[3]]
+		rest := strings.TrimSpace(text[where+5+len("{{ end }}"):])
+		ret = text[:loc[0]] + "{{ if .cursor }}WHERE " + pred + "{{ if " + cond + " }} AND ({{ end }}" +
+			"{{ else if " + cond + " }}WHERE ({{ end }}" + rest + "{{ if " + cond + " }}){{ end }}"
+
+	default:
+		rest := strings.TrimSpace(text[where+5:])
+		ret = text[:where] + "WHERE {{ if .cursor }}" + pred + " AND {{ end }}(" + rest + ")"
+	}
+	return ret + " ORDER BY " + strings.Join(orders, ", ") + " LIMIT " + bind("limit"), nil
+
+}
+
+// Return the derived "SELECT COUNT(*) FROM (...)" query text of a paged query.
+// The top level ORDER BY is removed and select list is replaced by "1" unless
+// it matters (DISTINCT/GROUP BY/HAVING/UNION/aggregate functions).
+func pageCountText(text string) (string, error) {
+
+	words, offsets := topLevelWords(text)
+	if len(words) == 0 || words[0] != "SELECT" {
+		return "", fmt.Errorf("page: expect SELECT query")
+	}
+	from, order, keep := -1, len(text), false
+	for i, word := range words {
+		switch word {
+		case "FROM":
+			if from < 0 {
+				from = offsets[i]
+			}
+		case "ORDER":
+			if order == len(text) {
+				order = offsets[i]
+			}
+		case "DISTINCT", "GROUP", "HAVING", "UNION", "COUNT", "SUM", "AVG", "MIN", "MAX", "GROUP_CONCAT":
+			keep = true
+		case "LIMIT", "FOR", "LOCK", "INTO":
+			return "", fmt.Errorf("page: %s is not allowed in paged query", word)
+		}
+	}
+
+	body := text[:order]
+	if !keep && from >= 0 && from < order {
+		body = text[:offsets[0]] + "SELECT 1 " + text[from:order]
+	}
+	ret := "SELECT COUNT(*) FROM (" + strings.TrimSpace(body) + ") AS page_"
+	if _, err := template.New("").Parse(ret); err != nil {
+		return "", fmt.Errorf("page: ORDER BY can't be in substitution block: %s", err)
+	}
+	return ret, nil
+
+}
+
+// Split query text of multi-row INSERT into text before the VALUES row, the row
+// and text after it (e.g. "ON DUPLICATE KEY UPDATE ...").
+func batchText(text string) (string, string, string, error) {
+
+	if strings.Contains(text, "{{") {
+		return "", "", "", fmt.Errorf("batch: substitution block is not allowed")
+	}
+	start := -1
+	words, offsets := topLevelWords(text)
+	for i, word := range words {
+		if word == "VALUES" || word == "VALUE" {
+			start = offsets[i] + len(word)
+			break
+		}
+	}
+	if start < 0 {
+		return "", "", "", fmt.Errorf("batch: expect INSERT ... VALUES (...)")
+	}
+	for start < len(text) && strings.IndexByte(" \t\r\n", text[start]) >= 0 {
+		start += 1
+	}
+
+	// Find the matching ")".
+	depth, end := 0, -1
+	for i := start; i < len(text) && end < 0; i++ {
+		switch c := text[i]; c {
+		case '\'', '"', '`':
+			for i += 1; i < len(text) && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i += 1
+				}
+			}
+		case '(':
+			depth += 1
+		case ')':
+			depth -= 1
+			if depth == 0 {
+				end = i + 1
+			}
+		}
+	}
+	if start >= len(text) || text[start] != '(' || end < 0 {
+		return "", "", "", fmt.Errorf("batch: expect INSERT ... VALUES (...)")
+	}
+	if strings.HasPrefix(strings.TrimSpace(text[end:]), ",") {
+		return "", "", "", fmt.Errorf("batch: expect only one row in VALUES")
+	}
+	return text[:start], text[start:end], text[end:], nil
+
+}
+
+// Arg returns the argument of the name or nil if not found.
+func (a *AnnotMeta) Arg(name string) *ArgAnnot {
+	for _, arg := range a.Args {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// Validate checks that args, bindings and variables used in substitution blocks
+// agree with each other: each binding and variable must be a declared arg and
+// each arg must be used.
+func (a *AnnotMeta) Validate() error {
+
+	used := make(map[string]bool)
+	fieldNames := make(map[string]string)
+	for _, arg := range a.Args {
+		if _, ok := used[arg.Name]; ok {
+			return fmt.Errorf("%s: duplicate arg %+q", a.FuncName, arg.Name)
+		}
+		used[arg.Name] = false
+		if prev, ok := fieldNames[arg.FieldName()]; ok {
+			return fmt.Errorf("%s: arg %+q and %+q have the same field name %+q", a.FuncName,
+				prev, arg.Name, arg.FieldName())
+		}
+		fieldNames[arg.FieldName()] = arg.Name
+	}
+
+	for _, binding := range a.Bindings {
+		if _, ok := used[binding.Name]; !ok {
+			return fmt.Errorf("%s: bind %+q is not declared by \"$arg:%s\"", a.FuncName,
+				binding.Name, binding.Name)
+		}
+		used[binding.Name] = true
+	}
+	for _, name := range a.GeneratedBindNames() {
+		used[name] = true
+	}
+
+	// Processed text is rendered as a template with args as dot.
+	tmpl, err := template.New(a.FuncName).Parse(a.Text)
+	if err != nil {
+		return fmt.Errorf("%s: invalid substitution block: %s", a.FuncName, err)
+	}
+	vars := []string{}
+	if tmpl.Tree != nil {
+		collectTemplateVars(tmpl.Tree.Root, false, &vars)
+	}
+	for _, name := range vars {
+		if _, ok := used[name]; !ok {
+			return fmt.Errorf("%s: variable %+q in substitution block is not declared by \"$arg:%s\"",
+				a.FuncName, name, name)
+		}
+		used[name] = true
+	}
+
+	for _, arg := range a.Args {
+		if !used[arg.Name] {
+			return fmt.Errorf("%s: arg %+q is not used", a.FuncName, arg.Name)
+		}
+	}
+	return nil
+
+}
+
+// Collect top level field names of dot (".x" or "$.x") referenced in template
+// node. rebound is true inside range/with where dot is no longer args.
+func collectTemplateVars(node parse.Node, rebound bool, vars *[]string) {
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectTemplateVars(child, rebound, vars)
+		}
+
+	case *parse.ActionNode:
+		collectTemplateVars(n.Pipe, rebound, vars)
+
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectTemplateVars(cmd, rebound, vars)
+		}
+
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectTemplateVars(arg, rebound, vars)
+		}
+
+	case *parse.IfNode:
+		collectTemplateVars(n.Pipe, rebound, vars)
+		collectTemplateVars(n.List, rebound, vars)
+		collectTemplateVars(n.ElseList, rebound, vars)
+
+	case *parse.RangeNode:
+		collectTemplateVars(n.Pipe, rebound, vars)
+		collectTemplateVars(n.List, true, vars)
+		collectTemplateVars(n.ElseList, rebound, vars)
+
+	case *parse.WithNode:
+		collectTemplateVars(n.Pipe, rebound, vars)
+		collectTemplateVars(n.List, true, vars)
+		collectTemplateVars(n.ElseList, rebound, vars)
+
+	case *parse.TemplateNode:
+		collectTemplateVars(n.Pipe, rebound, vars)
+
+	case *parse.FieldNode:
+		if !rebound {
+			*vars = append(*vars, n.Ident[0])
+		}
+
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			*vars = append(*vars, n.Ident[1])
+		}
+
+	}
+
+}
+
+// GeneratedBindNames returns names of args bound by annotations ("$paginate"/"$page")
+// instead of "$bind".
+func (a *AnnotMeta) GeneratedBindNames() []string {
+	ret := []string{}
+	if a.Paginate != nil {
+		ret = append(ret, "limit")
+		for _, arg := range a.Paginate.KeyArgs {
+			ret = append(ret, arg.Name)
+		}
+	}
+	if a.CountMeta != nil {
+		ret = append(ret, "limit", "offset")
+	}
+	return ret
+}
+
+// FuncArgs returns non-internal arguments.
+func (a *AnnotMeta) FuncArgs() []*ArgAnnot {
+	ret := []*ArgAnnot{}
+	for _, arg := range a.Args {
+		if !arg.Internal {
+			ret = append(ret, arg)
+		}
+	}
+	return ret
+}
+
+// RequiredArgs returns non-optional (and non-internal) arguments.
+func (a *AnnotMeta) RequiredArgs() []*ArgAnnot {
+	ret := []*ArgAnnot{}
+	for _, arg := range a.Args {
+		if !arg.Optional && !arg.Internal {
+			ret = append(ret, arg)
+		}
+	}
+	return ret
+}
+
+// OptionalArgs returns optional arguments.
+func (a *AnnotMeta) OptionalArgs() []*ArgAnnot {
+	ret := []*ArgAnnot{}
+	for _, arg := range a.Args {
+		if arg.Optional {
+			ret = append(ret, arg)
+		}
+	}
+	return ret
 }
 
 func (a *AnnotMeta) Env(key string) string {
@@ -0,0 +1,161 @@
+package annot
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Fragment is a reusable piece of query text declared in DML files by
+// "/*$fragment:name*/ ... /*$end*/" and referenced by "/*$use:name*/".
+type Fragment struct {
+	// Fragment name.
+	Name string
+
+	// Fragment text in a single line: line comments without annotation are
+	// stripped and those with annotation are converted to block comments.
+	Text string
+
+	// Position of declaration: "file:line".
+	Pos string
+}
+
+// Fragments contains fragments declared in DML files.
+type Fragments struct {
+	fragments map[string]*Fragment
+}
+
+func NewFragments() *Fragments {
+	return &Fragments{
+		fragments: make(map[string]*Fragment),
+	}
+}
+
+// Return "file:line" of offset in src.
+func sourcePos(fileName, src string, offset int) string {
+	return fmt.Sprintf("%s:%d", fileName, strings.Count(src[:offset], "\n")+1)
+}
+
+// Extract adds fragments declared in src and returns src with the declarations
+// replaced by new lines so that line numbers of the rest are kept.
+func (f *Fragments) Extract(fileName, src string) (string, error) {
+
+	comments, err := ScanComment(src)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	offset := 0
+	for i := 0; i < len(comments); i++ {
+		a, ok := comments[i].Annot.(*FragmentAnnot)
+		if !ok {
+			continue
+		}
+		pos := sourcePos(fileName, src, comments[i].Offset)
+		if prev, ok := f.fragments[a.Name]; ok {
+			return "", fmt.Errorf("fragment: %q (%s) is already declared at %s", a.Name, pos, prev.Pos)
+		}
+
+		// Find the "$end", skipping those of "$optional".
+		depth, j := 0, i+1
+	Loop:
+		for ; j < len(comments); j++ {
+			switch comments[j].Annot.(type) {
+			case *FragmentAnnot:
+				return "", fmt.Errorf("fragment: %q (%s) is not ended before another \"$fragment\"", a.Name, pos)
+			case *OptionalAnnot:
+				depth += 1
+			case *EndAnnot:
+				if depth == 0 {
+					break Loop
+				}
+				depth -= 1
+			}
+		}
+		if j >= len(comments) {
+			return "", fmt.Errorf("fragment: %q (%s) is not ended by \"$end\"", a.Name, pos)
+		}
+
+		// Build the single line text.
+		text := &bytes.Buffer{}
+		textOffset := comments[i].Offset + comments[i].Length
+		for _, comment := range comments[i+1 : j] {
+			text.WriteString(src[textOffset:comment.Offset])
+			switch {
+			case src[comment.Offset] == '/':
+				text.WriteString(src[comment.Offset : comment.Offset+comment.Length])
+			case comment.Annot != nil:
+				text.WriteString("/*" + comment.Content + "*/ ")
+			default:
+				text.WriteString(" ")
+			}
+			textOffset = comment.Offset + comment.Length
+		}
+		text.WriteString(src[textOffset:comments[j].Offset])
+		f.fragments[a.Name] = &Fragment{
+			Name: a.Name,
+			Text: strings.TrimSpace(strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text.String())),
+			Pos:  pos,
+		}
+
+		end := comments[j].Offset + comments[j].Length
+		buf.WriteString(src[offset:comments[i].Offset])
+		buf.WriteString(strings.Repeat("\n", strings.Count(src[comments[i].Offset:end], "\n")))
+		offset = end
+		i = j
+	}
+	buf.WriteString(src[offset:])
+	return buf.String(), nil
+
+}
+
+// Expand replaces "$use" annotations in src with fragment text recursively.
+func (f *Fragments) Expand(fileName, src string) (string, error) {
+	return f.expand(src, func(offset int) string {
+		return sourcePos(fileName, src, offset)
+	}, nil)
+}
+
+func (f *Fragments) expand(src string, pos func(int) string, stack []string) (string, error) {
+
+	comments, err := ScanComment(src)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	offset := 0
+	for _, comment := range comments {
+		a, ok := comment.Annot.(*UseAnnot)
+		if !ok {
+			continue
+		}
+		for i, name := range stack {
+			if name == a.Name {
+				return "", fmt.Errorf("use: cycle of fragments %s (%s)",
+					strings.Join(append(stack[i:], a.Name), " -> "), pos(comment.Offset))
+			}
+		}
+		fragment, ok := f.fragments[a.Name]
+		if !ok {
+			return "", fmt.Errorf("use: unknown fragment %q (%s)", a.Name, pos(comment.Offset))
+		}
+		text, err := f.expand(fragment.Text, func(int) string {
+			return "fragment " + fragment.Name + " at " + fragment.Pos
+		}, append(stack, a.Name))
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(src[offset:comment.Offset])
+		buf.WriteString(" " + text + " ")
+		if src[comment.Offset+comment.Length-1] == '\n' {
+			// Keep the new line of line comment.
+			buf.WriteString("\n")
+		}
+		offset = comment.Offset + comment.Length
+	}
+	buf.WriteString(src[offset:])
+	return buf.String(), nil
+
+}
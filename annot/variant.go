@@ -0,0 +1,259 @@
+package annot
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"text/template"
+	"text/template/parse"
+)
+
+// MaxVariants is the max number of variants enumerated for a dynamic query.
+var MaxVariants = 64
+
+// Variant is a possible query text of a dynamic query (query with "if"/"with"
+// blocks in substitution annotations).
+type Variant struct {
+	// Query text with placeholder contents kept.
+	Text string
+
+	// Conditions leading to this variant, e.g. ".title: false".
+	Choices []string
+}
+
+// Variants enumerates query texts under combinations of conditions of "if"/"with"
+// in substitution blocks. Conditions composed by "and"/"or"/"not" are evaluated
+// from their operands. "range" blocks are rendered once. A deterministic sample
+// is returned if there are more than MaxVariants combinations. Returns error
+// if the substitution blocks contain actions other than conditions.
+func (a *AnnotMeta) Variants() ([]*Variant, error) {
+
+	tmpl, err := template.New(a.FuncName).Parse(a.SampleText)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Tree == nil {
+		return []*Variant{{Text: a.SampleText}}, nil
+	}
+	root := tmpl.Tree.Root
+
+	// Collect atomic conditions.
+	atoms := []string{}
+	seenAtoms := make(map[string]bool)
+	var collect func(parse.Node)
+	collect = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				collect(child)
+			}
+		case *parse.IfNode:
+			collectAtoms(n.Pipe, &atoms, seenAtoms)
+			collect(n.List)
+			collect(n.ElseList)
+		case *parse.WithNode:
+			collectAtoms(n.Pipe, &atoms, seenAtoms)
+			collect(n.List)
+			collect(n.ElseList)
+		case *parse.RangeNode:
+			collect(n.List)
+		}
+	}
+	collect(root)
+
+	ret := []*Variant{}
+	seen := make(map[string]bool)
+	for _, choices := range branchChoices(len(atoms), MaxVariants) {
+		values := make(map[string]bool)
+		descs := []string{}
+		for i, atom := range atoms {
+			values[atom] = choices[i]
+			descs = append(descs, fmt.Sprintf("%s: %v", atom, choices[i]))
+		}
+		buf := &bytes.Buffer{}
+		if err := renderVariant(root, values, buf); err != nil {
+			return nil, err
+		}
+		text := buf.String()
+		if seen[text] {
+			continue
+		}
+		seen[text] = true
+		ret = append(ret, &Variant{
+			Text:    text,
+			Choices: descs,
+		})
+	}
+	return ret, nil
+
+}
+
+// Return the "and"/"or"/"not" command and its operands if node is one.
+func logicOp(node parse.Node) (string, []parse.Node) {
+	for {
+		pipe, ok := node.(*parse.PipeNode)
+		if !ok || len(pipe.Decl) != 0 || len(pipe.Cmds) != 1 {
+			break
+		}
+		node = pipe.Cmds[0]
+	}
+	cmd, ok := node.(*parse.CommandNode)
+	if !ok {
+		return "", nil
+	}
+	if len(cmd.Args) == 1 {
+		return logicOp(cmd.Args[0])
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		switch ident.Ident {
+		case "and", "or", "not":
+			return ident.Ident, cmd.Args[1:]
+		}
+	}
+	return "", nil
+}
+
+// Condition's text without redundant pipe/command wrapping.
+func condString(node parse.Node) string {
+	for {
+		switch n := node.(type) {
+		case *parse.PipeNode:
+			if len(n.Decl) == 0 && len(n.Cmds) == 1 {
+				node = n.Cmds[0]
+				continue
+			}
+		case *parse.CommandNode:
+			if len(n.Args) == 1 {
+				node = n.Args[0]
+				continue
+			}
+		}
+		return node.String()
+	}
+}
+
+func collectAtoms(node parse.Node, atoms *[]string, seen map[string]bool) {
+	if op, operands := logicOp(node); op != "" {
+		for _, operand := range operands {
+			collectAtoms(operand, atoms, seen)
+		}
+		return
+	}
+	atom := condString(node)
+	if !seen[atom] {
+		seen[atom] = true
+		*atoms = append(*atoms, atom)
+	}
+}
+
+func evalCond(node parse.Node, values map[string]bool) bool {
+	op, operands := logicOp(node)
+	switch op {
+	case "and":
+		for _, operand := range operands {
+			if !evalCond(operand, values) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, operand := range operands {
+			if evalCond(operand, values) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		return !evalCond(operands[0], values)
+	}
+	return values[condString(node)]
+}
+
+// Return combinations of n boolean choices. If there are more than max
+// combinations, all true, all false, single flips and random ones are returned.
+func branchChoices(n int, max int) [][]bool {
+
+	ret := [][]bool{}
+	if n < 30 && 1<<uint(n) <= max {
+		for mask := 0; mask < 1<<uint(n); mask++ {
+			choices := make([]bool, n)
+			for i := 0; i < n; i++ {
+				choices[i] = mask&(1<<uint(i)) != 0
+			}
+			ret = append(ret, choices)
+		}
+		return ret
+	}
+
+	fill := func(val bool) []bool {
+		choices := make([]bool, n)
+		for i := range choices {
+			choices[i] = val
+		}
+		return choices
+	}
+	ret = append(ret, fill(true), fill(false))
+	for i := 0; i < n && len(ret) < max; i++ {
+		choices := fill(false)
+		choices[i] = true
+		ret = append(ret, choices)
+	}
+	for i := 0; i < n && len(ret) < max; i++ {
+		choices := fill(true)
+		choices[i] = false
+		ret = append(ret, choices)
+	}
+	rnd := rand.New(rand.NewSource(int64(n)))
+	for len(ret) < max {
+		choices := make([]bool, n)
+		for i := range choices {
+			choices[i] = rnd.Intn(2) == 1
+		}
+		ret = append(ret, choices)
+	}
+	return ret
+
+}
+
+func renderVariant(node parse.Node, values map[string]bool, buf *bytes.Buffer) error {
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := renderVariant(child, values, buf); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *parse.TextNode:
+		buf.Write(n.Text)
+		return nil
+
+	case *parse.IfNode:
+		return renderBranch(&n.BranchNode, values, buf)
+
+	case *parse.WithNode:
+		return renderBranch(&n.BranchNode, values, buf)
+
+	case *parse.RangeNode:
+		return renderVariant(n.List, values, buf)
+
+	default:
+		return fmt.Errorf("unsupported %q in substitution block", node.String())
+	}
+
+}
+
+func renderBranch(branch *parse.BranchNode, values map[string]bool, buf *bytes.Buffer) error {
+	if evalCond(branch.Pipe, values) {
+		return renderVariant(branch.List, values, buf)
+	}
+	return renderVariant(branch.ElseList, values, buf)
+}
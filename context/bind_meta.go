@@ -0,0 +1,256 @@
+package context
+
+import (
+	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/parser/opcode"
+	"github.com/pingcap/tidb/util/types"
+	"strconv"
+)
+
+// BindMeta contains meta information of a query binding inferred from the
+// statement it is used in.
+type BindMeta struct {
+	// Bind arg name.
+	Name string
+
+	// Inferred field type, nil if can't be inferred.
+	Type *types.FieldType
+
+	// Not nil if the binding is directly compared with (or assigned to) a column
+	// in default database.
+	Column *ColumnMeta
+
+	// True if the binding is the only element of an "IN (...)" list.
+	InList bool
+
+	// True if the list is of "NOT IN (...)".
+	NotIn bool
+}
+
+// Placeholder contents are replaced by these integers to locate bindings in
+// the compiled statement.
+const bindSentinelBase int64 = 7000000000000
+
+// NewBindMetas infers types of query bindings in the annotated statement. The
+// returned list is in the same order as annotMeta.Bindings.
+func NewBindMetas(ctx *Context, annotMeta *annot.AnnotMeta) ([]*BindMeta, error) {
+
+	ret := make([]*BindMeta, 0, len(annotMeta.Bindings))
+	if len(annotMeta.Bindings) == 0 {
+		return ret, nil
+	}
+
+	// Replace placeholder contents with sentinels.
+	src := annotMeta.SrcText
+	text := ""
+	offset := 0
+	sentinels := make(map[int64]int)
+	for i, binding := range annotMeta.Bindings {
+		sentinel := bindSentinelBase + int64(i)
+		text += src[offset:binding.Offset] + " " + strconv.FormatInt(sentinel, 10) + " "
+		offset = binding.Offset + binding.Length
+		sentinels[sentinel] = i
+		ret = append(ret, &BindMeta{
+			Name: binding.Name,
+		})
+	}
+	text += src[offset:]
+
+	db := ctx.DB
+	stmts, err := db.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, fmt.Errorf("Expect exactly one statement but got %d", len(stmts))
+	}
+	stmt := stmts[0]
+	if _, err := db.Compile(stmt); err != nil {
+		return nil, err
+	}
+
+	// Table references are used to resolve columns in assignments.
+	var refs *ast.TableRefsClause
+	switch s := stmt.(type) {
+	case *ast.SelectStmt:
+		refs = s.From
+	case *ast.InsertStmt:
+		refs = s.Table
+	case *ast.UpdateStmt:
+		refs = s.TableRefs
+	case *ast.DeleteStmt:
+		refs = s.TableRefs
+	}
+	refsMeta, err := NewTableRefsMeta(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.Accept(&bindTypeCollector{
+		ctx:       ctx,
+		refs:      refsMeta,
+		sentinels: sentinels,
+		binds:     ret,
+	})
+	return ret, nil
+
+}
+
+type bindTypeCollector struct {
+	ctx       *Context
+	refs      *TableRefsMeta
+	sentinels map[int64]int
+	binds     []*BindMeta
+}
+
+func (c *bindTypeCollector) Enter(n ast.Node) (ast.Node, bool) {
+
+	switch x := n.(type) {
+	case *ast.BinaryOperationExpr:
+		switch x.Op {
+		case opcode.EQ, opcode.NE, opcode.LT, opcode.LE, opcode.GT, opcode.GE, opcode.NullEQ:
+			c.compare(x.L, x.R)
+			c.compare(x.R, x.L)
+		}
+
+	case *ast.PatternInExpr:
+		// "col IN (:binding)" means the binding is a list.
+		if len(x.List) == 1 {
+			if i := c.bindIndex(x.List[0]); i >= 0 {
+				c.binds[i].InList = true
+				c.binds[i].NotIn = x.Not
+			}
+		}
+		for _, e := range x.List {
+			c.compare(e, x.Expr)
+		}
+
+	case *ast.BetweenExpr:
+		c.compare(x.Left, x.Expr)
+		c.compare(x.Right, x.Expr)
+
+	case *ast.PatternLikeExpr:
+		c.compare(x.Pattern, x.Expr)
+
+	case *ast.Assignment:
+		c.assign(x.Expr, c.column(x.Column))
+
+	case *ast.InsertStmt:
+		for _, row := range x.Lists {
+			for i, e := range row {
+				var col *ColumnMeta
+				if len(x.Columns) > 0 {
+					if i < len(x.Columns) {
+						col = c.column(x.Columns[i])
+					}
+				} else if len(c.refs.TableMetas) > 0 && c.refs.TableMetas[0] != nil {
+					if cols := c.refs.TableMetas[0].Columns; i < len(cols) {
+						col = cols[i]
+					}
+				}
+				c.assign(e, col)
+			}
+		}
+
+	}
+	return n, false
+
+}
+
+func (c *bindTypeCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// Return the index of binding if the expression is a sentinel or -1.
+func (c *bindTypeCollector) bindIndex(e ast.ExprNode) int {
+	for {
+		p, ok := e.(*ast.ParenthesesExpr)
+		if !ok {
+			break
+		}
+		e = p.Expr
+	}
+	v, ok := e.(*ast.ValueExpr)
+	if !ok {
+		return -1
+	}
+	val, ok := v.GetValue().(int64)
+	if !ok {
+		return -1
+	}
+	i, ok := c.sentinels[val]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+// Binding expression is compared with other expression.
+func (c *bindTypeCollector) compare(e, other ast.ExprNode) {
+
+	i := c.bindIndex(e)
+	if i < 0 || c.bindIndex(other) >= 0 || c.binds[i].Type != nil {
+		return
+	}
+
+	var (
+		ft  *types.FieldType
+		col *ColumnMeta
+	)
+	if cn, ok := other.(*ast.ColumnNameExpr); ok && cn.Refer != nil {
+		col = c.ctx.ColumnByResultField(cn.Refer)
+		if cn.Refer.Column != nil {
+			ft = &cn.Refer.Column.FieldType
+		}
+	}
+	if ft == nil {
+		ft = other.GetType()
+	}
+	if ft == nil || ft.Tp == mysql.TypeNull {
+		return
+	}
+
+	// A value compared with is never NULL.
+	t := *ft
+	t.Flag |= mysql.NotNullFlag
+
+	bind := c.binds[i]
+	bind.Type = &t
+	bind.Column = col
+
+}
+
+// Binding expression is assigned to a column.
+func (c *bindTypeCollector) assign(e ast.ExprNode, col *ColumnMeta) {
+
+	i := c.bindIndex(e)
+	if i < 0 || col == nil || c.binds[i].Type != nil {
+		return
+	}
+	bind := c.binds[i]
+	bind.Type = col.Type
+	if col.Table.DB.Name == c.ctx.DBName {
+		bind.Column = col
+	}
+
+}
+
+// Resolve column name in table references.
+func (c *bindTypeCollector) column(cn *ast.ColumnName) *ColumnMeta {
+	for i, tableMeta := range c.refs.TableMetas {
+		if tableMeta == nil {
+			continue
+		}
+		if cn.Table.L != "" && cn.Table.L != c.refs.TableRefNames[i] &&
+			cn.Table.L != tableMeta.Name {
+			continue
+		}
+		if col := tableMeta.ColumnByName(cn.Name.L); col != nil {
+			return col
+		}
+	}
+	return nil
+}
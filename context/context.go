@@ -2,6 +2,7 @@ package context
 
 import (
 	"fmt"
+	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/model"
 )
 
@@ -81,3 +82,22 @@ func (ctx *Context) UniqueTableName(dbName, tableName string) string {
 	return tableName
 
 }
+
+// ColumnByResultField returns the column (in default database) that the result
+// field directly references, or nil if not found.
+func (ctx *Context) ColumnByResultField(rf *ast.ResultField) *ColumnMeta {
+
+	if rf.Table == nil || rf.Column == nil || rf.DBName.L != ctx.DBName {
+		return nil
+	}
+	dbMeta, err := ctx.GetDBMeta(ctx.DBName)
+	if err != nil {
+		return nil
+	}
+	tableMeta, ok := dbMeta.Tables[rf.Table.Name.L]
+	if !ok {
+		return nil
+	}
+	return tableMeta.ColumnByName(rf.Column.Name.L)
+
+}
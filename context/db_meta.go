@@ -2,6 +2,7 @@ package context
 
 import (
 	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
 	"github.com/huangjunwen/JustSQL/utils"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
@@ -30,11 +31,19 @@ func NewDBMeta(ctx *Context, dbInfo *model.DBInfo) (*DBMeta, error) {
 		PascalName: utils.PascalCase(dbInfo.Name.L),
 		Tables:     make(map[string]*TableMeta),
 	}
+	structNames := make(map[string]string)
 	for _, tableInfo := range dbInfo.Tables {
 		tableMeta, err := NewTableMeta(ctx, ret, tableInfo)
 		if err != nil {
 			return nil, err
 		}
+		if !tableMeta.Skip {
+			if name, ok := structNames[tableMeta.PascalName]; ok {
+				return nil, fmt.Errorf("Table %q and %q have the same Go name %q", name,
+					tableMeta.Name, tableMeta.PascalName)
+			}
+			structNames[tableMeta.PascalName] = tableMeta.Name
+		}
 		ret.Tables[tableMeta.Name] = tableMeta
 	}
 	return ret, nil
@@ -49,10 +58,19 @@ type TableMeta struct {
 	Name       string
 	PascalName string
 
+	// Table comment text with annotations stripped.
+	Comment string
+
 	Columns     []*ColumnMeta
 	Indices     []*IndexMeta
 	ForeignKeys []*FKMeta
 
+	// From annotations in table comment.
+	Skip     bool             // No code should be generated for this table.
+	ReadOnly bool             // No Insert/Update/Delete methods.
+	Finders  []*FinderMeta    // Extra finders.
+	Tags     *annot.TagsAnnot // Extra struct tags, nil if not declared.
+
 	// Shortcut
 	primaryIndex  *IndexMeta
 	autoIncColumn *ColumnMeta
@@ -78,6 +96,12 @@ func NewTableMeta(ctx *Context, dbMeta *DBMeta, tableInfo *model.TableInfo) (*Ta
 		if err != nil {
 			return nil, err
 		}
+		for _, c := range ret.Columns {
+			if c.PascalName == columnMeta.PascalName {
+				return nil, fmt.Errorf("Column %q and %q in table %q have the same Go name %q",
+					c.Name, columnMeta.Name, ret.Name, c.PascalName)
+			}
+		}
 		ret.Columns = append(ret.Columns, columnMeta)
 		ret.columnByName[columnMeta.Name] = columnMeta
 
@@ -116,6 +140,47 @@ func NewTableMeta(ctx *Context, dbMeta *DBMeta, tableInfo *model.TableInfo) (*Ta
 		ret.ForeignKeys = append(ret.ForeignKeys, fkMeta)
 	}
 
+	// Annotations in table comment.
+	comment, annots, err := annot.ParseDDLComment(tableInfo.Comment)
+	if err != nil {
+		return nil, fmt.Errorf("Table %s comment: %s", ret.Name, err)
+	}
+	ret.Comment = comment
+	for _, a := range annots {
+		switch an := a.(type) {
+		case *annot.StructAnnot:
+			ret.PascalName = an.Name
+		case *annot.SkipAnnot:
+			ret.Skip = true
+		case *annot.ReadOnlyAnnot:
+			ret.ReadOnly = true
+		case *annot.TagsAnnot:
+			ret.Tags = an
+		case *annot.FinderAnnot:
+			finderMeta, err := NewFinderMeta(ctx, ret, an)
+			if err != nil {
+				return nil, err
+			}
+			ret.Finders = append(ret.Finders, finderMeta)
+		default:
+			return nil, fmt.Errorf("Table %s comment: %T is not allowed", ret.Name, a)
+		}
+	}
+
+	// Extra finders share names with finders of unique indices (By<Index>).
+	finderNames := make(map[string]bool)
+	for _, index := range ret.Indices {
+		if index.Unique {
+			finderNames["By"+index.PascalName] = true
+		}
+	}
+	for _, finder := range ret.Finders {
+		if finderNames[finder.Name] {
+			return nil, fmt.Errorf("Table %s finder %s: duplicated finder name", ret.Name, finder.Name)
+		}
+		finderNames[finder.Name] = true
+	}
+
 	return ret, nil
 }
 
@@ -179,17 +244,43 @@ type ColumnMeta struct {
 
 	// Column field type.
 	Type *types.FieldType
+
+	// Column comment text with annotations stripped.
+	Comment string
+
+	// Custom Go type spec from "$type" annotation in column comment, or "".
+	GoType string
 }
 
 func NewColumnMeta(ctx *Context, tableMeta *TableMeta, columnInfo *model.ColumnInfo) (*ColumnMeta, error) {
-	return &ColumnMeta{
+	ret := &ColumnMeta{
 		ColumnInfo: columnInfo,
 		Table:      tableMeta,
 		Name:       columnInfo.Name.L,
 		PascalName: utils.PascalCase(columnInfo.Name.L),
 		Offset:     columnInfo.Offset,
 		Type:       &columnInfo.FieldType,
-	}, nil
+	}
+
+	// Annotations in column comment.
+	comment, annots, err := annot.ParseDDLComment(columnInfo.Comment)
+	if err != nil {
+		return nil, fmt.Errorf("Column %s.%s comment: %s", tableMeta.Name, ret.Name, err)
+	}
+	ret.Comment = comment
+	for _, a := range annots {
+		switch an := a.(type) {
+		case *annot.TypeAnnot:
+			ret.GoType = an.Type
+		case *annot.NameAnnot:
+			ret.PascalName = an.Name
+		default:
+			return nil, fmt.Errorf("Column %s.%s comment: %T is not allowed", tableMeta.Name,
+				ret.Name, a)
+		}
+	}
+
+	return ret, nil
 }
 
 func (c *ColumnMeta) IsEnum() bool {
@@ -200,6 +291,10 @@ func (c *ColumnMeta) IsSet() bool {
 	return c.Type.Tp == mysql.TypeSet
 }
 
+func (c *ColumnMeta) IsJSON() bool {
+	return c.Type.Tp == mysql.TypeJSON
+}
+
 func (c *ColumnMeta) Elems() []string {
 	return c.Type.Elems
 }
@@ -276,6 +371,37 @@ func (i *IndexMeta) Columns() []*ColumnMeta {
 	return ret
 }
 
+// FinderMeta contains meta data of an extra finder declared in table comment.
+type FinderMeta struct {
+	Table *TableMeta
+
+	// Finder name (without struct name prefix).
+	Name string
+
+	Columns []*ColumnMeta
+
+	// Return one entry instead of a list of entries.
+	ReturnOne bool
+}
+
+func NewFinderMeta(ctx *Context, tableMeta *TableMeta, a *annot.FinderAnnot) (*FinderMeta, error) {
+	ret := &FinderMeta{
+		Table:     tableMeta,
+		Name:      a.Name,
+		Columns:   make([]*ColumnMeta, 0, len(a.Columns)),
+		ReturnOne: a.ReturnStyle == annot.ReturnOne,
+	}
+	for _, colName := range a.Columns {
+		col := tableMeta.ColumnByName(colName)
+		if col == nil {
+			return nil, fmt.Errorf("Table %s finder %s: column %q not found", tableMeta.Name,
+				a.Name, colName)
+		}
+		ret.Columns = append(ret.Columns, col)
+	}
+	return ret, nil
+}
+
 // FKMeta contains meta data of a foreign key.
 type FKMeta struct {
 	*model.FKInfo
@@ -2,6 +2,7 @@ package context
 
 import (
 	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
 	"github.com/huangjunwen/JustSQL/utils"
 	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/mysql"
@@ -47,6 +48,10 @@ func (rf *ResultFieldMeta) IsSet() bool {
 	return rf.Type.Tp == mysql.TypeSet
 }
 
+func (rf *ResultFieldMeta) IsJSON() bool {
+	return rf.Type.Tp == mysql.TypeJSON
+}
+
 func (rf *ResultFieldMeta) Elems() []string {
 	return rf.Type.Elems
 }
@@ -434,6 +439,19 @@ func NewSelectStmtMeta(ctx *Context, stmt *ast.SelectStmt) (*SelectStmtMeta, err
 
 }
 
+// WildcardOnlyTable return the table if the result fields are exactly one table
+// wildcard (e.g. "SELECT * FROM user" or "SELECT u.* FROM user u JOIN ...")
+// or nil otherwise.
+func (s *SelectStmtMeta) WildcardOnlyTable() *TableMeta {
+
+	wildcards := s.FieldList.Wildcards
+	if len(wildcards) != 1 || wildcards[0].ResultFieldNum != len(s.ResultFields) {
+		return nil
+	}
+	return s.TableRefs.TableMeta(wildcards[0].TableRefName)
+
+}
+
 var wildcardRe *regexp.Regexp = regexp.MustCompile(
 	`^((([A-Za-z][A-Za-z0-9_]*)` + "|" + "(`[A-Za-z][A-Za-z0-9_]*`)" + `)\s*\.\s*){0,2}\*`)
 
@@ -553,6 +571,93 @@ func (s *SelectStmtMeta) ExpandWildcard(ctx *Context) (*SelectStmtMeta, error) {
 
 }
 
+// GroupTableMeta contains meta information of a wildcard table in grouping.
+type GroupTableMeta struct {
+	TableRefName string
+
+	Table *TableMeta
+
+	// Primary key columns used to de-duplicate rows.
+	KeyColumns []*ColumnMeta
+}
+
+// GroupMeta contains meta information of folding rows of a SELECT statement into
+// parent/children results.
+type GroupMeta struct {
+	Parent *GroupTableMeta
+
+	Children []*GroupTableMeta
+}
+
+// NewGroupMeta create GroupMeta. stmtMeta should be the one before wildcard
+// expansion. All result fields must be in the parent's or children's wildcards.
+func NewGroupMeta(ctx *Context, stmtMeta *SelectStmtMeta, a *annot.GroupAnnot) (*GroupMeta, error) {
+
+	seen := make(map[string]bool)
+	newGroupTable := func(tableRefName string) (*GroupTableMeta, error) {
+		if seen[tableRefName] {
+			return nil, fmt.Errorf("group: table %+q appears more than once", tableRefName)
+		}
+		seen[tableRefName] = true
+
+		found := false
+		for _, wildcard := range stmtMeta.FieldList.Wildcards {
+			if wildcard.TableRefName == tableRefName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("group: no wildcard \"%s.*\" in SELECT", tableRefName)
+		}
+
+		tableMeta := stmtMeta.TableRefs.TableMeta(tableRefName)
+		if tableMeta == nil || tableMeta.DB.Name != ctx.DBName || tableMeta.Skip {
+			return nil, fmt.Errorf("group: %+q is not a table in current database", tableRefName)
+		}
+		keyColumns := tableMeta.PrimaryColumns()
+		if len(keyColumns) == 0 {
+			return nil, fmt.Errorf("group: table %+q has no primary key", tableMeta.Name)
+		}
+		return &GroupTableMeta{
+			TableRefName: tableRefName,
+			Table:        tableMeta,
+			KeyColumns:   keyColumns,
+		}, nil
+	}
+
+	ret := &GroupMeta{}
+	var err error
+	if ret.Parent, err = newGroupTable(a.Parent); err != nil {
+		return nil, err
+	}
+	for _, child := range a.Children {
+		childMeta, err := newGroupTable(child)
+		if err != nil {
+			return nil, err
+		}
+		ret.Children = append(ret.Children, childMeta)
+	}
+
+	for i, rf := range stmtMeta.ResultFields {
+		if !seen[stmtMeta.FieldList.WildcardTableRefName(i)] {
+			return nil, fmt.Errorf("group: result field %+q is not in grouping tables", rf.Name)
+		}
+	}
+	return ret, nil
+
+}
+
+// ChildIndex return the index of the child or -1 if not found.
+func (g *GroupMeta) ChildIndex(tableRefName string) int {
+	for i, child := range g.Children {
+		if child.TableRefName == tableRefName {
+			return i
+		}
+	}
+	return -1
+}
+
 // InsertStmtMeta contains meta information of a INSERT statement.
 type InsertStmtMeta struct {
 	*ast.InsertStmt
@@ -1,6 +1,5 @@
 -- $func:UsersByIds
 -- $arg:userIds type:[]int
--- $env hasInBinding:true
 SELECT * FROM user WHERE id IN (/*$bind:userIds*/1/**/);
 
 -- $func:QueryBlogById return:one
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
 	"github.com/huangjunwen/JustSQL/context"
 	"github.com/huangjunwen/JustSQL/render"
 	// Remember to import builtin templates. Otherwise files will be
@@ -52,6 +53,14 @@ func Initialize() {
 	if err != nil {
 		log.Fatalf("NewRenderer(): %s", err)
 	}
+	// Already checked in option parsing.
+	renderer.Tags, _ = ParseTags(options)
+	renderer.ParamsStruct = options.ParamsStruct
+	renderer.EmptyInNull = options.EmptyIn == "null"
+	for _, jsonType := range options.JSONTypes {
+		tableName, columnName, typeSpec, _ := ParseJSONType(jsonType)
+		renderer.TypeAdapter.BindJSONType(tableName, columnName, typeSpec)
+	}
 
 }
 
@@ -198,10 +207,27 @@ func LoadDDL() {
 
 	}
 
-	if _, err := ctx.GetDBMeta(ctx.DBName); err != nil {
+	dbMeta, err := ctx.GetDBMeta(ctx.DBName)
+	if err != nil {
 		log.Fatalf("LoadDDL(): GetDBMeta(%+q) got error: %s", ctx.DBName, err)
 	}
 
+	// Check JSON type bindings.
+	for _, jsonType := range options.JSONTypes {
+		tableName, columnName, _, _ := ParseJSONType(jsonType)
+		tableMeta, ok := dbMeta.Tables[tableName]
+		if !ok {
+			log.Fatalf("LoadDDL(): JSON type binding %+q: table not found", jsonType)
+		}
+		columnMeta := tableMeta.ColumnByName(columnName)
+		if columnMeta == nil {
+			log.Fatalf("LoadDDL(): JSON type binding %+q: column not found", jsonType)
+		}
+		if !columnMeta.IsJSON() {
+			log.Fatalf("LoadDDL(): JSON type binding %+q: not a JSON column", jsonType)
+		}
+	}
+
 	log.Infof("LoadDDL(): ended.")
 
 }
@@ -273,6 +299,11 @@ func OutputTables() {
 
 	for _, tableMeta := range dbMeta.Tables {
 
+		if tableMeta.Skip {
+			log.Infof("OutputTables(): table %+q skipped", tableMeta.Name)
+			continue
+		}
+
 		log.Infof("OutputTables(): table %+q", tableMeta.Name)
 
 		scope := fmt.Sprintf("%s.tb.go", tableMeta.Name)
@@ -296,19 +327,58 @@ func LoadAndOutputDML() {
 	iter := ReadFilesFromGlobs(options.DML)
 	log.Infof("LoadAndOutputDML(): starts...")
 
+	// Wrapper function name -> file declaring it. All generated files are in the
+	// same package so function names must be unique.
+	funcNames := make(map[string]string)
+
+	// Extract fragments from all files first since they can be used across files.
+	fragments := annot.NewFragments()
+	fileNames, fileContents := []string{}, []string{}
 	for fileName, fileContent, ok := iter(); ok; fileName, fileContent, ok = iter() {
+		content, err := fragments.Extract(fileName, string(fileContent))
+		if err != nil {
+			log.Fatalf("LoadAndOutputDML(): file %+q, %s", fileName, err)
+		}
+		fileNames = append(fileNames, fileName)
+		fileContents = append(fileContents, content)
+	}
 
-		scope := fmt.Sprintf("%s.go", filepath.Base(fileName))
-		renderer.Scopes.SwitchScope(scope)
+	for i, fileName := range fileNames {
 
-		var buf bytes.Buffer
+		// Expand fragments.
+		content, err := fragments.Expand(fileName, fileContents[i])
+		if err != nil {
+			log.Fatalf("LoadAndOutputDML(): file %+q, %s", fileName, err)
+		}
 
 		// Parse.
-		stmts, err := db.Parse(string(fileContent))
+		stmts, err := db.Parse(content)
 		if err != nil {
 			log.Fatalf("LoadAndOutputDML(): parsing %+q error %s", fileName, err)
 		}
 
+		// Skip files containing fragments only.
+		if len(stmts) == 0 {
+			continue
+		}
+
+		scope := fmt.Sprintf("%s.go", filepath.Base(fileName))
+		renderer.Scopes.SwitchScope(scope)
+
+		var buf bytes.Buffer
+
+		// Consecutive statements with the same "$tx".
+		var txFunc *render.TxFunc
+		renderTxFunc := func() {
+			if txFunc == nil {
+				return
+			}
+			if err := renderer.Render(txFunc, &buf); err != nil {
+				log.Fatalf("Renderer.Render(%q): %s", scope, err)
+			}
+			txFunc = nil
+		}
+
 		// Check and render stmts.
 		for _, stmt := range stmts {
 
@@ -321,11 +391,37 @@ func LoadAndOutputDML() {
 				log.Fatalf("LoadAndOutputDML(): file %+q, %T is not an allowed DML. ", fileName, stmt)
 			}
 
+			funcName, txName, err := checkFuncName(funcNames, fileName, stmtText)
+			if err != nil {
+				log.Fatalf("LoadAndOutputDML(): file %+q, %s", fileName, err)
+			}
+
 			if err := renderer.Render(stmt, &buf); err != nil {
 				log.Fatalf("Renderer.Render(%q): %s", scope, err)
 			}
 
+			if txFunc != nil && txFunc.Name != txName {
+				renderTxFunc()
+			}
+			if txName == "" {
+				continue
+			}
+			if funcName == "" {
+				log.Fatalf("LoadAndOutputDML(): file %+q, statement in \"$tx:%s\" must declare \"$func\"",
+					fileName, txName)
+			}
+			if txFunc == nil {
+				if prev, ok := funcNames[txName]; ok {
+					log.Fatalf("LoadAndOutputDML(): file %+q, duplicate function name %+q (already declared in %+q)",
+						fileName, txName, prev)
+				}
+				funcNames[txName] = fileName
+				txFunc = &render.TxFunc{Name: txName}
+			}
+			txFunc.FuncNames = append(txFunc.FuncNames, funcName)
+
 		}
+		renderTxFunc()
 
 		OutputFile(scope, &buf)
 
@@ -335,6 +431,31 @@ func LoadAndOutputDML() {
 
 }
 
+// Check that "$func" name in the statement is not declared before. Returns "$func"
+// and "$tx" names ("" if not declared).
+func checkFuncName(funcNames map[string]string, fileName, stmtText string) (string, string, error) {
+
+	comments, err := annot.ScanComment(stmtText)
+	if err != nil {
+		return "", "", err
+	}
+	funcName, txName := "", ""
+	for _, comment := range comments {
+		switch a := comment.Annot.(type) {
+		case *annot.FuncAnnot:
+			if prev, ok := funcNames[a.Name]; ok {
+				return "", "", fmt.Errorf("duplicate function name %+q (already declared in %+q)", a.Name, prev)
+			}
+			funcNames[a.Name] = fileName
+			funcName = a.Name
+		case *annot.TxAnnot:
+			txName = a.Name
+		}
+	}
+	return funcName, txName, nil
+
+}
+
 func OutputStandalone() {
 
 	scope := "justsql.go"
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
 	"github.com/huangjunwen/JustSQL/utils"
 	"os"
 	"path/filepath"
@@ -43,14 +44,20 @@ func (m *MutipleValues) UnmarshalJSON(data []byte) error {
 }
 
 type Options struct {
-	OutputDir         string        `json:"o"`     // Output directory.
-	LogLevel          string        `json:"ll"`    // Log level (fatal/error/warn/info/debug).
-	DDL               MutipleValues `json:"ddl"`   // DDL files.
-	DML               MutipleValues `json:"dml"`   // DML files.
-	NoFormat          bool          `json:"nofmt"` // Do not go format output files.
-	CustomTemplateDir MutipleValues `json:"t"`     // Add custom template set directory.
-	TemplateSetName   string        `json:"T"`     // Explicitly specify template set name for renderring.
-	AllNullTypes      bool          `json:"null"`  // Use sql.NullInt64/sql.NullString for all types even the field is NOT NULL.
+	OutputDir         string        `json:"o"`         // Output directory.
+	LogLevel          string        `json:"ll"`        // Log level (fatal/error/warn/info/debug).
+	DDL               MutipleValues `json:"ddl"`       // DDL files.
+	DML               MutipleValues `json:"dml"`       // DML files.
+	NoFormat          bool          `json:"nofmt"`     // Do not go format output files.
+	CustomTemplateDir MutipleValues `json:"t"`         // Add custom template set directory.
+	TemplateSetName   string        `json:"T"`         // Explicitly specify template set name for renderring.
+	AllNullTypes      bool          `json:"null"`      // Use sql.NullInt64/sql.NullString for all types even the field is NOT NULL.
+	JSONTypes         MutipleValues `json:"json"`      // Bind JSON columns to Go types: "table.column=pkgPath.Type".
+	Tags              string        `json:"tags"`      // Extra struct tags for generated structs: "json,yaml".
+	TagNaming         string        `json:"tagnaming"` // Naming convention of extra struct tags: snake/camel/original.
+	TagOmitEmpty      bool          `json:"omitempty"` // Add "omitempty" to extra struct tags of nullable fields.
+	ParamsStruct      bool          `json:"params"`    // Generated DML functions take a single params struct instead of positional arguments.
+	EmptyIn           string        `json:"emptyin"`   // Empty slice in "IN (...)": error/null.
 }
 
 func ParseOptions() *Options {
@@ -93,6 +100,12 @@ func ParseOptions() *Options {
 	flag.Var(&options.CustomTemplateDir, "t", "Add custom templates set in specified directory. Multiple \"-t\" is allowed.")
 	flag.StringVar(&options.TemplateSetName, "T", "", "Explicitly specify template set name for renderring.")
 	flag.BoolVar(&options.AllNullTypes, "null", false, "Use sql.NullInt64/sql.NullString ... for all types even the field is NOT NULL.")
+	flag.StringVar(&options.Tags, "tags", "", "Extra struct tags for generated structs, e.g. \"json,yaml\".")
+	flag.StringVar(&options.TagNaming, "tagnaming", "", "Naming convention of extra struct tags: snake/camel/original, default: snake.")
+	flag.BoolVar(&options.TagOmitEmpty, "omitempty", false, "Add \"omitempty\" to extra struct tags of nullable fields.")
+	flag.BoolVar(&options.ParamsStruct, "params", false, "Generated DML functions take a single params struct instead of positional arguments.")
+	flag.StringVar(&options.EmptyIn, "emptyin", "", "Empty slice in \"IN (...)\": error (return error) or null (use \"IN (NULL)\"), default: error.")
+	flag.Var(&options.JSONTypes, "json", "Bind a JSON column to a Go type: \"table.column=pkgPath.Type\". Multiple \"-json\" is allowed.")
 	flag.Parse()
 
 	if help {
@@ -147,6 +160,22 @@ func ParseOptions() *Options {
 		if options.AllNullTypes || configOptions.AllNullTypes {
 			options.AllNullTypes = true
 		}
+		options.JSONTypes = append(configOptions.JSONTypes, options.JSONTypes...)
+		if options.Tags == "" && configOptions.Tags != "" {
+			options.Tags = configOptions.Tags
+		}
+		if options.TagNaming == "" && configOptions.TagNaming != "" {
+			options.TagNaming = configOptions.TagNaming
+		}
+		if options.TagOmitEmpty || configOptions.TagOmitEmpty {
+			options.TagOmitEmpty = true
+		}
+		if options.ParamsStruct || configOptions.ParamsStruct {
+			options.ParamsStruct = true
+		}
+		if options.EmptyIn == "" && configOptions.EmptyIn != "" {
+			options.EmptyIn = configOptions.EmptyIn
+		}
 	} else {
 		// Yield error only when config file is explicit.
 		if explicitConfigFile {
@@ -163,6 +192,14 @@ func ParseOptions() *Options {
 		printUsageAndExit(fmt.Errorf("Unknown log level %+q", options.LogLevel))
 	}
 
+	switch options.EmptyIn {
+	case "error", "null":
+	case "":
+		options.EmptyIn = "error"
+	default:
+		printUsageAndExit(fmt.Errorf("Unknown -emptyin %+q", options.EmptyIn))
+	}
+
 	if options.OutputDir == "" {
 		printUsageAndExit(fmt.Errorf("Missing -o"))
 	}
@@ -182,5 +219,50 @@ func ParseOptions() *Options {
 	}
 	options.CustomTemplateDir = MutipleValues(absTempateDirs)
 
+	for _, jsonType := range options.JSONTypes {
+		if _, _, _, err := ParseJSONType(jsonType); err != nil {
+			printUsageAndExit(err)
+		}
+	}
+
+	if _, err := ParseTags(options); err != nil {
+		printUsageAndExit(err)
+	}
+
 	return options
 }
+
+// ParseJSONType parses a JSON column binding "table.column=pkgPath.Type".
+func ParseJSONType(s string) (tableName, columnName, typeSpec string, err error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", "", fmt.Errorf("Bad JSON type binding %+q, expect \"table.column=pkgPath.Type\"", s)
+	}
+	names := strings.Split(parts[0], ".")
+	if len(names) != 2 || !utils.IsIdent(names[0]) || !utils.IsIdent(names[1]) {
+		return "", "", "", fmt.Errorf("Bad JSON column %+q in binding %+q", parts[0], s)
+	}
+	return strings.ToLower(names[0]), strings.ToLower(names[1]), parts[1], nil
+}
+
+// ParseTags creates TagsAnnot from tag options.
+func ParseTags(options *Options) (*annot.TagsAnnot, error) {
+	ret := &annot.TagsAnnot{}
+	if err := ret.SetPrimary(options.Tags); err != nil {
+		return nil, err
+	}
+	if options.TagNaming != "" {
+		if err := ret.Set("naming", options.TagNaming); err != nil {
+			return nil, err
+		}
+	}
+	if options.TagOmitEmpty {
+		if err := ret.Set("omitempty", "true"); err != nil {
+			return nil, err
+		}
+	}
+	if err := ret.Set("", ""); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -2,6 +2,7 @@ package render
 
 import (
 	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
 	"github.com/huangjunwen/JustSQL/context"
 	"github.com/huangjunwen/JustSQL/utils"
 	ts "github.com/pingcap/tidb/util/types"
@@ -101,6 +102,35 @@ func append_(val interface{}, items ...interface{}) (ret string, err error) {
 	return
 }
 
+// List is a list of arbitrary values, e.g. *TypeName which should only be
+// converted to string when actually used in output.
+type List struct {
+	items []interface{}
+}
+
+func NewList() *List {
+	return &List{
+		items: []interface{}{},
+	}
+}
+
+func (l *List) Append(items ...interface{}) error {
+	l.items = append(l.items, items...)
+	return nil
+}
+
+func (l *List) Items() []interface{} {
+	return l.items
+}
+
+func (l *List) Len() int {
+	return len(l.items)
+}
+
+func (l *List) Index(i int) interface{} {
+	return l.items[i]
+}
+
 // --- String helpers ---
 
 // StringList is just a list of strings.
@@ -226,6 +256,15 @@ func join(val interface{}, sep string) (string, error) {
 
 // --- Source code helpers ---
 
+// Format text as Go line comments.
+func comment(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("// "+line, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Import pkg into the renderred source code.
 func buildImp(r *Renderer) func(string) *PkgName {
 	return func(pkgPath string) *PkgName {
@@ -239,8 +278,15 @@ func buildTypeName(r *Renderer) func(interface{}) (*TypeName, error) {
 		case *ts.FieldType:
 			return r.TypeAdapter.AdaptType(v), nil
 		case *context.ColumnMeta:
-			return r.TypeAdapter.AdaptType(v.Type), nil
+			return columnTypeName(r, v), nil
 		case *context.ResultFieldMeta:
+			// Result field directly referencing a column (in current database)
+			// having custom type shares the column's type.
+			if col := r.Context.ColumnByResultField(v.ResultField); col != nil {
+				if col.GoType != "" || boundJSONType(r, col) != nil {
+					return columnTypeName(r, col), nil
+				}
+			}
 			return r.TypeAdapter.AdaptType(v.Type), nil
 		case string:
 			return r.Scopes.CreateTypeNameFromSpec(v), nil
@@ -250,6 +296,144 @@ func buildTypeName(r *Renderer) func(interface{}) (*TypeName, error) {
 	}
 }
 
+func columnTypeName(r *Renderer, col *context.ColumnMeta) *TypeName {
+	// JSON column bound to a Go type uses the wrapper type generated
+	// along with the table.
+	if boundJSONType(r, col) != nil {
+		return r.Scopes.CreateTypeName("", col.Table.PascalName+col.PascalName)
+	}
+	// Custom type from column comment.
+	if col.GoType != "" {
+		return r.Scopes.CreateTypeNameFromSpec(col.GoType)
+	}
+	return r.TypeAdapter.AdaptType(col.Type)
+}
+
+// Return the Go type bound to a JSON column, or nil if not bound. Binding can be
+// declared by "$type" annotation in column comment or by TypeAdapter.BindJSONType.
+func boundJSONType(r *Renderer, col *context.ColumnMeta) *TypeName {
+	if !col.IsJSON() || col.Table.DB.Name != r.Context.DBName || col.Table.Skip {
+		return nil
+	}
+	if col.GoType != "" {
+		return r.Scopes.CreateTypeNameFromSpec(col.GoType)
+	}
+	return r.TypeAdapter.JSONType(col.Table.Name, col.Name)
+}
+
+// Return Go expression of a key column value that can be used in map keys: []byte
+// values are converted to string. Returns error if the column's Go type is not
+// comparable.
+func buildKeyExpr(r *Renderer) func(*context.ColumnMeta, string) (string, error) {
+	return func(col *context.ColumnMeta, expr string) (string, error) {
+		typeName := columnTypeName(r, col)
+		switch spec := typeName.Spec(); {
+		case spec == "[]byte" || spec == "[]uint8":
+			return "string(" + expr + ")", nil
+		case !comparableType(typeName, col):
+			return "", fmt.Errorf("key column %+q of Go type %s is not comparable", col.Name, spec)
+		}
+		return expr, nil
+	}
+}
+
+// Return true if the shared result struct should be declared (first time seen)
+// or false if it has been declared with the same signature.
+func buildDeclareResult(r *Renderer) func(string, string) (bool, error) {
+	return func(name, signature string) (bool, error) {
+		if name == "" {
+			return true, nil
+		}
+		prev, ok := r.SharedResults[name]
+		if !ok {
+			r.SharedResults[name] = signature
+			return true, nil
+		}
+		if prev != signature {
+			return false, fmt.Errorf("Result struct %+q is shared by queries with different result fields:\n\t%s\n\t%s",
+				name, prev, signature)
+		}
+		return false, nil
+	}
+}
+
+// Return the field type of the column in generated table struct: enum/set columns
+// and bound JSON columns use wrapper types generated along with the table.
+func buildFieldType(r *Renderer) func(*context.ColumnMeta) *TypeName {
+	return func(col *context.ColumnMeta) *TypeName {
+		if col.GoType == "" && (col.IsEnum() || col.IsSet()) {
+			return r.Scopes.CreateTypeName("", col.Table.PascalName+col.PascalName)
+		}
+		return columnTypeName(r, col)
+	}
+}
+
+func buildJSONType(r *Renderer) func(interface{}) (*TypeName, error) {
+	return func(val interface{}) (*TypeName, error) {
+		switch v := val.(type) {
+		case *context.ColumnMeta:
+			return boundJSONType(r, v), nil
+		case *context.ResultFieldMeta:
+			if col := r.Context.ColumnByResultField(v.ResultField); col != nil {
+				return boundJSONType(r, col), nil
+			}
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("jsonType: not support %T as argument", v)
+		}
+	}
+}
+
+// Return extra struct tags (e.g. `json:"fill_time,omitempty" yaml:"fill_time,omitempty"`)
+// of a field. val can be *context.ColumnMeta, *context.ResultFieldMeta or a name string
+// for a non-nullable field.
+func structTags(tags *annot.TagsAnnot, val interface{}) (string, error) {
+	if tags == nil || len(tags.Names) == 0 {
+		return "", nil
+	}
+
+	var (
+		name     string
+		nullable bool
+	)
+	switch v := val.(type) {
+	case *context.ColumnMeta:
+		name = v.ColumnInfo.Name.O
+		nullable = !v.IsNotNULL()
+	case *context.ResultFieldMeta:
+		name = v.ColumnAsName.O
+		if name == "" && v.Column != nil {
+			name = v.Column.Name.O
+		}
+		if name == "" {
+			// Computed field.
+			name = v.Name
+		}
+		nullable = !v.IsNotNULL()
+	case string:
+		name = v
+	default:
+		return "", fmt.Errorf("tags: not support %T as argument", v)
+	}
+
+	switch tags.Naming {
+	case "camel":
+		name = utils.CamelCase(name)
+	case "original":
+	default:
+		name = utils.SnakeCase(name)
+	}
+	if nullable && tags.OmitEmpty {
+		name += ",omitempty"
+	}
+
+	parts := make([]string, 0, len(tags.Names))
+	for _, tagName := range tags.Names {
+		parts = append(parts, fmt.Sprintf("%s:%q", tagName, name))
+	}
+	return strings.Join(parts, " "), nil
+}
+
 func buildCast(r *Renderer) func(string, *TypeName, *TypeName) (string, error) {
 	ta := r.TypeAdapter
 	return func(srcExpr string, srcTypeName, dstTypeName *TypeName) (string, error) {
@@ -315,6 +499,19 @@ func buildDBName(r *Renderer) func() string {
 	}
 }
 
+// Return "comparable" if values of the type can be compared with its zero value
+// using "==", "slice" for slices or "" if unknown (e.g. maps, funcs or struct of
+// other packages).
+func zeroKind(typeName *TypeName) string {
+	if typeName.IsSlice() {
+		return "slice"
+	}
+	if argKind(typeName, false) != "" {
+		return "comparable"
+	}
+	return ""
+}
+
 func BuildExtraFuncs(r *Renderer) template.FuncMap {
 
 	fnMap := template.FuncMap{
@@ -323,6 +520,7 @@ func BuildExtraFuncs(r *Renderer) template.FuncMap {
 		"first":  first,
 		"last":   last,
 		"append": append_,
+		"list":   NewList,
 		// String helpers.
 		"pascal":           utils.PascalCase,
 		"camel":            utils.CamelCase,
@@ -332,9 +530,16 @@ func BuildExtraFuncs(r *Renderer) template.FuncMap {
 		"dup":              dup,
 		"join":             join,
 		// Source code helpers.
-		"imp":      buildImp(r),
-		"typeName": buildTypeName(r),
-		"cast":     buildCast(r),
+		"imp":           buildImp(r),
+		"comment":       comment,
+		"tags":          structTags,
+		"typeName":      buildTypeName(r),
+		"jsonType":      buildJSONType(r),
+		"fieldType":     buildFieldType(r),
+		"declareResult": buildDeclareResult(r),
+		"cast":          buildCast(r),
+		"keyExpr":       buildKeyExpr(r),
+		"zeroKind":      zeroKind,
 		// Database helpers.
 		"columnList":  NewColumnList,
 		"columnNames": columnNames,
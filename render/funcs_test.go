@@ -0,0 +1,60 @@
+package render
+
+import (
+	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
+	"github.com/huangjunwen/JustSQL/context"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
+	ts "github.com/pingcap/tidb/util/types"
+	"testing"
+)
+
+func TestStructTags(t *testing.T) {
+	fmt.Println("TestStructTags")
+	tags := &annot.TagsAnnot{Names: []string{"json"}, Naming: "snake"}
+	ft := &ts.FieldType{Tp: mysql.TypeLonglong, Flag: mysql.NotNullFlag}
+
+	for _, c := range []struct {
+		rf     *ast.ResultField
+		name   string
+		expect string
+	}{
+		// Column.
+		{&ast.ResultField{Column: &model.ColumnInfo{Name: model.NewCIStr("userId")}}, "userId", `json:"user_id"`},
+		// Aliased.
+		{&ast.ResultField{Column: &model.ColumnInfo{Name: model.NewCIStr("id")}, ColumnAsName: model.NewCIStr("uid")},
+			"uid", `json:"uid"`},
+		// Computed field without column.
+		{&ast.ResultField{}, "COUNT(*)", `json:"count"`},
+	} {
+		rf := &context.ResultFieldMeta{ResultField: c.rf, Name: c.name, Type: ft}
+		tag, err := structTags(tags, rf)
+		if err != nil || tag != c.expect {
+			t.Errorf("Expect %q but got %q %v\n", c.expect, tag, err)
+		}
+	}
+}
+
+func TestZeroKind(t *testing.T) {
+	fmt.Println("TestZeroKind")
+	scopes := NewScopes()
+	for _, c := range []struct {
+		spec   string
+		expect string
+	}{
+		{"int", "comparable"},
+		{"string", "comparable"},
+		{"*time.Time", "comparable"},
+		{"[]int", "slice"},
+		// Compared by reflection.
+		{"time.Time", ""},
+		{"map[string]int", ""},
+		{"func()", ""},
+	} {
+		if kind := zeroKind(scopes.CreateTypeNameFromSpec(c.spec)); kind != c.expect {
+			t.Errorf("Expect zero kind of %s to be %q but got %q\n", c.spec, c.expect, kind)
+		}
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"github.com/huangjunwen/JustSQL/annot"
 	"github.com/huangjunwen/JustSQL/context"
+	"github.com/ngaut/log"
 	"github.com/pingcap/tidb/ast"
+	"path"
+	"strings"
 )
 
 func handleTableMeta(r *Renderer, obj interface{}) (interface{}, error) {
@@ -14,9 +17,15 @@ func handleTableMeta(r *Renderer, obj interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("handleTableMeta: expect *context.TableMeta but got %T", obj)
 	}
 
+	tags := r.Tags
+	if tableMeta.Tags != nil {
+		tags = tableMeta.Tags
+	}
+
 	// The 'dot' object to render TableMeta
 	return map[string]interface{}{
 		"Table": tableMeta,
+		"Tags":  tags,
 	}, nil
 }
 
@@ -43,8 +52,42 @@ func handleSelectStmt(r *Renderer, obj interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkStmtAnnots(annotMeta, "SELECT"); err != nil {
+		return nil, err
+	}
+	var paginateKeys []int
+	if annotMeta.Paginate != nil {
+		if paginateKeys, err = paginateKeyIndices(r, originStmtMeta, stmtMeta, annotMeta.Paginate); err != nil {
+			return nil, err
+		}
+	}
+	bindMetas, err := inferArgTypes(r, annotMeta)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOptionals(r, annotMeta); err != nil {
+		return nil, err
+	}
+	if err := checkVariants(r, annotMeta); err != nil {
+		return nil, err
+	}
+	if annotMeta.CountMeta != nil {
+		if err := checkCountQuery(r, annotMeta.CountMeta); err != nil {
+			return nil, err
+		}
+	}
+	inBindings, err := listBindings(r, annotMeta, bindMetas)
+	if err != nil {
+		return nil, err
+	}
 	switch annotMeta.ReturnStyle {
-	case annot.ReturnMany, annot.ReturnOne:
+	case annot.ReturnMany, annot.ReturnOne, annot.ReturnEach:
+	case annot.ReturnScalar, annot.ReturnColumn:
+		if len(stmtMeta.ResultFields) != 1 {
+			return nil, fmt.Errorf("Wrapper function's return %+q requires exactly one result column but got %d",
+				annotMeta.ReturnStyle, len(stmtMeta.ResultFields))
+		}
+	case annot.ReturnMap:
 	case annot.ReturnUnknown:
 		// Default return many for select.
 		annotMeta.ReturnStyle = annot.ReturnMany
@@ -53,10 +96,114 @@ func handleSelectStmt(r *Renderer, obj interface{}) (interface{}, error) {
 			annotMeta.ReturnStyle)
 	}
 
+	if annotMeta.ResultName != "" {
+		switch annotMeta.ReturnStyle {
+		case annot.ReturnScalar, annot.ReturnColumn:
+			return nil, fmt.Errorf("$result can't be used with return style %+q", annotMeta.ReturnStyle)
+		}
+	}
+
+	unwrapName := ""
+	if annotMeta.Unwrap {
+		if annotMeta.ResultName != "" || annotMeta.Group != nil {
+			return nil, fmt.Errorf("unwrap can't be used with $result or $group")
+		}
+		switch annotMeta.ReturnStyle {
+		case annot.ReturnScalar, annot.ReturnColumn:
+			return nil, fmt.Errorf("unwrap can't be used with return style %+q", annotMeta.ReturnStyle)
+		}
+		tableMeta := originStmtMeta.WildcardOnlyTable()
+		if tableMeta == nil || tableMeta.DB.Name != ctx.DBName || tableMeta.Skip {
+			return nil, fmt.Errorf("unwrap requires the query to select exactly one wildcard " +
+				"(\"SELECT t.* ...\") of a table in current database")
+		}
+		unwrapName = tableMeta.PascalName
+	}
+
+	var groupMeta *context.GroupMeta
+	if annotMeta.Group != nil {
+		switch annotMeta.ReturnStyle {
+		case annot.ReturnMany, annot.ReturnOne:
+		default:
+			return nil, fmt.Errorf("Wrapper function's return can't be %+q for SELECT with $group",
+				annotMeta.ReturnStyle)
+		}
+		if groupMeta, err = context.NewGroupMeta(ctx, originStmtMeta, annotMeta.Group); err != nil {
+			return nil, err
+		}
+		keyExpr := buildKeyExpr(r)
+		for _, groupTable := range append([]*context.GroupTableMeta{groupMeta.Parent}, groupMeta.Children...) {
+			for _, col := range groupTable.KeyColumns {
+				if _, err := keyExpr(col, ""); err != nil {
+					return nil, fmt.Errorf("$group: %s", err)
+				}
+			}
+		}
+	}
+
+	if annotMeta.Paginate != nil && (annotMeta.ReturnStyle != annot.ReturnMany || annotMeta.Group != nil) {
+		return nil, fmt.Errorf("$paginate can only be used with return style \"many\" and without $group")
+	}
+	if annotMeta.CountMeta != nil && (annotMeta.ReturnStyle != annot.ReturnMany || annotMeta.Group != nil) {
+		return nil, fmt.Errorf("$page can only be used with return style \"many\" and without $group")
+	}
+
+	for _, orderBy := range annotMeta.OrderBys {
+		for _, key := range orderBy.Allow {
+			if _, err := resultFieldIndex(stmtMeta, key); err != nil {
+				return nil, fmt.Errorf("$orderBy:%s: %s", orderBy.Name, err)
+			}
+		}
+	}
+
+	mapKeyIndex := -1
+	var mapKeyType *TypeName
+	if annotMeta.ReturnStyle == annot.ReturnMap {
+		if mapKeyIndex, err = resultFieldIndex(stmtMeta, annotMeta.MapKey); err != nil {
+			return nil, err
+		}
+		rf := stmtMeta.ResultFields[mapKeyIndex]
+		keyType, err := resultFieldTypeName(r, originStmtMeta, stmtMeta, mapKeyIndex)
+		if err != nil {
+			return nil, err
+		}
+		mapKeyType = keyType
+		if !comparableType(keyType, r.Context.ColumnByResultField(rf.ResultField)) {
+			return nil, fmt.Errorf("Map key column %+q of Go type %s is not comparable", annotMeta.MapKey,
+				keyType.Spec())
+		}
+	}
+
+	builder, err := sqlBuilder(r, annotMeta, inBindings)
+	if err != nil {
+		return nil, err
+	}
+	var countSQLBuilder *SQLBuilder
+	if annotMeta.CountMeta != nil {
+		if countSQLBuilder, err = sqlBuilder(r, annotMeta.CountMeta, inBindings); err != nil {
+			return nil, err
+		}
+	}
+
+	tags := r.Tags
+	if annotMeta.Tags != nil {
+		tags = annotMeta.Tags
+	}
+
 	return map[string]interface{}{
-		"OriginStmt": originStmtMeta,
-		"Stmt":       stmtMeta,
-		"Annot":      annotMeta,
+		"OriginStmt":      originStmtMeta,
+		"Stmt":            stmtMeta,
+		"Annot":           annotMeta,
+		"Tags":            tags,
+		"MapKeyIndex":     mapKeyIndex,
+		"MapKeyType":      mapKeyType,
+		"Group":           groupMeta,
+		"UnwrapName":      unwrapName,
+		"ParamsStruct":    useParamsStruct(r, annotMeta),
+		"PaginateKeys":    paginateKeys,
+		"InBindings":      inBindings,
+		"SQLBuilder":      builder,
+		"CountSQLBuilder": countSQLBuilder,
 	}, nil
 
 }
@@ -79,10 +226,49 @@ func handleInsertStmt(r *Renderer, obj interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkStmtAnnots(annotMeta, "INSERT"); err != nil {
+		return nil, err
+	}
+	bindMetas, err := inferArgTypes(r, annotMeta)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOptionals(r, annotMeta); err != nil {
+		return nil, err
+	}
+	if err := checkVariants(r, annotMeta); err != nil {
+		return nil, err
+	}
+	inBindings, err := listBindings(r, annotMeta, bindMetas)
+	if err != nil {
+		return nil, err
+	}
+
+	// Batch INSERT always returns the first auto increment id and rows affected.
+	var builder *SQLBuilder
+	if annotMeta.Batch != nil {
+		if annotMeta.ReturnStyle != annot.ReturnUnknown {
+			return nil, fmt.Errorf("Wrapper function's return can't be declared for $batch")
+		}
+		if builder, err = NewSQLBuilder(r, annotMeta, inBindings); err != nil {
+			return nil, fmt.Errorf("%s: %s", annotMeta.FuncName, err)
+		}
+	} else {
+		if err := checkExecReturnStyle(annotMeta, "INSERT", annot.ReturnRowsAffected, annot.ReturnExec, annot.ReturnLastInsertId); err != nil {
+			return nil, err
+		}
+		if builder, err = sqlBuilder(r, annotMeta, inBindings); err != nil {
+			return nil, err
+		}
+	}
+	r.ExecFuncs[annotMeta.FuncName] = annotMeta
 
 	return map[string]interface{}{
-		"Stmt":  stmtMeta,
-		"Annot": annotMeta,
+		"Stmt":         stmtMeta,
+		"Annot":        annotMeta,
+		"ParamsStruct": useParamsStruct(r, annotMeta),
+		"InBindings":   inBindings,
+		"SQLBuilder":   builder,
 	}, nil
 
 }
@@ -105,10 +291,39 @@ func handleDeleteStmt(r *Renderer, obj interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkStmtAnnots(annotMeta, "DELETE"); err != nil {
+		return nil, err
+	}
+	bindMetas, err := inferArgTypes(r, annotMeta)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOptionals(r, annotMeta); err != nil {
+		return nil, err
+	}
+	if err := checkVariants(r, annotMeta); err != nil {
+		return nil, err
+	}
+	inBindings, err := listBindings(r, annotMeta, bindMetas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExecReturnStyle(annotMeta, "DELETE", annot.ReturnRowsAffected, annot.ReturnExec); err != nil {
+		return nil, err
+	}
+	builder, err := sqlBuilder(r, annotMeta, inBindings)
+	if err != nil {
+		return nil, err
+	}
+	r.ExecFuncs[annotMeta.FuncName] = annotMeta
 
 	return map[string]interface{}{
-		"Stmt":  stmtMeta,
-		"Annot": annotMeta,
+		"Stmt":         stmtMeta,
+		"Annot":        annotMeta,
+		"ParamsStruct": useParamsStruct(r, annotMeta),
+		"InBindings":   inBindings,
+		"SQLBuilder":   builder,
 	}, nil
 
 }
@@ -131,10 +346,515 @@ func handleUpdateStmt(r *Renderer, obj interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkStmtAnnots(annotMeta, "UPDATE"); err != nil {
+		return nil, err
+	}
+	bindMetas, err := inferArgTypes(r, annotMeta)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOptionals(r, annotMeta); err != nil {
+		return nil, err
+	}
+	if err := checkVariants(r, annotMeta); err != nil {
+		return nil, err
+	}
+	inBindings, err := listBindings(r, annotMeta, bindMetas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExecReturnStyle(annotMeta, "UPDATE", annot.ReturnRowsAffected, annot.ReturnExec); err != nil {
+		return nil, err
+	}
+	builder, err := sqlBuilder(r, annotMeta, inBindings)
+	if err != nil {
+		return nil, err
+	}
+	r.ExecFuncs[annotMeta.FuncName] = annotMeta
+
+	return map[string]interface{}{
+		"Stmt":         stmtMeta,
+		"Annot":        annotMeta,
+		"ParamsStruct": useParamsStruct(r, annotMeta),
+		"InBindings":   inBindings,
+		"SQLBuilder":   builder,
+	}, nil
+
+}
+
+// Return true if values of the Go type can be compared (e.g. used as map keys).
+// Slices (e.g. []byte of BINARY/BLOB columns), json.RawMessage, NullRawMessage and
+// types of JSON columns can't. col is the column having the type, or nil. Types
+// declared in other packages are assumed to be comparable.
+func comparableType(typeName *TypeName, col *context.ColumnMeta) bool {
+	spec := typeName.Spec()
+	if strings.HasPrefix(spec, "[]") || (col != nil && col.IsJSON()) {
+		return false
+	}
+	switch spec {
+	case "encoding/json.RawMessage", "NullRawMessage":
+		return false
+	}
+	return true
+}
+
+// Return the index of result field named "col" or "table.col" ("table" can be
+// table name or alias).
+func resultFieldIndex(stmtMeta *context.SelectStmtMeta, name string) (int, error) {
+
+	tableName, colName := "", strings.ToLower(name)
+	if i := strings.LastIndex(colName, "."); i >= 0 {
+		tableName, colName = colName[:i], colName[i+1:]
+	}
+
+	ret := -1
+	for i, rf := range stmtMeta.ResultFields {
+		if rf.Name != colName {
+			continue
+		}
+		if tableName != "" && rf.TableAsName.L != tableName &&
+			(rf.Table == nil || rf.Table.Name.L != tableName) {
+			continue
+		}
+		if ret >= 0 {
+			return -1, fmt.Errorf("Result column %+q is ambiguous, please use \"table.column\"", name)
+		}
+		ret = i
+	}
+	if ret < 0 {
+		return -1, fmt.Errorf("Result column %+q not found", name)
+	}
+	return ret, nil
+
+}
+
+// Return result field indices of pagination keys and fill types of key args. Keys
+// must be NOT NULL columns containing a unique index of each table in FROM clause,
+// so that they are unique in result rows even if some tables are joined.
+func paginateKeyIndices(r *Renderer, originStmtMeta, stmtMeta *context.SelectStmtMeta, p *annot.PaginateAnnot) ([]int, error) {
+
+	ret := []int{}
+	// Table ref name -> key columns.
+	keyColumns := make(map[string]map[*context.ColumnMeta]bool)
+	for i, key := range p.By {
+		idx, err := resultFieldIndex(stmtMeta, key)
+		if err != nil {
+			return nil, fmt.Errorf("$paginate: %s", err)
+		}
+		rf := stmtMeta.ResultFields[idx]
+		col := r.Context.ColumnByResultField(rf.ResultField)
+		if col == nil {
+			return nil, fmt.Errorf("$paginate: key %+q is not a column of table in current database", key)
+		}
+		if !col.IsNotNULL() {
+			return nil, fmt.Errorf("$paginate: key %+q is nullable", key)
+		}
+		// Key arg has the same type as the result field encoded in cursor.
+		typeName, err := resultFieldTypeName(r, originStmtMeta, stmtMeta, idx)
+		if err != nil {
+			return nil, err
+		}
+		p.KeyArgs[i].Type = typeName.Spec()
+		tableRefName := rf.TableAsName.L
+		if tableRefName == "" {
+			tableRefName = r.Context.UniqueTableName(rf.DBName.L, rf.Table.Name.L)
+		}
+		if keyColumns[tableRefName] == nil {
+			keyColumns[tableRefName] = make(map[*context.ColumnMeta]bool)
+		}
+		keyColumns[tableRefName][col] = true
+		ret = append(ret, idx)
+	}
+
+	refs := stmtMeta.TableRefs
+	for i, tableRefName := range refs.TableRefNames {
+		if !coverUniqueIndex(refs.TableMetas[i], keyColumns[tableRefName]) {
+			return nil, fmt.Errorf("$paginate: keys %+q do not contain a unique index of table %+q",
+				strings.Join(p.By, ","), tableRefName)
+		}
+	}
+	return ret, nil
+
+}
+
+// Return true if columns contain a unique index of the table (nil for derived table).
+func coverUniqueIndex(t *context.TableMeta, columns map[*context.ColumnMeta]bool) bool {
+	if t == nil {
+		return false
+	}
+	for _, index := range t.Indices {
+		if !index.Unique {
+			continue
+		}
+		covered := true
+		for _, c := range index.Columns() {
+			covered = covered && columns[c]
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the table of the wildcard which the i-th result field belongs to if the
+// table is in current database (and not skipped), so that the field is in the
+// table struct. Otherwise return nil.
+func wildcardTable(r *Renderer, stmtMeta *context.SelectStmtMeta, i int) *context.TableMeta {
+	t := stmtMeta.TableRefs.TableMeta(stmtMeta.FieldList.WildcardTableRefName(i))
+	if t == nil || t.DB.Name != r.Context.DBName || t.Skip {
+		return nil
+	}
+	return t
+}
+
+// Return the Go type of the i-th result field as in the result struct: field type of
+// the table struct (e.g. enum/set wrapper type) if it's from a wildcard.
+func resultFieldTypeName(r *Renderer, originStmtMeta, stmtMeta *context.SelectStmtMeta, i int) (*TypeName, error) {
+	rf := stmtMeta.ResultFields[i]
+	if wildcardTable(r, originStmtMeta, i) != nil {
+		if col := r.Context.ColumnByResultField(rf.ResultField); col != nil {
+			return buildFieldType(r)(col), nil
+		}
+	}
+	return buildTypeName(r)(rf)
+}
+
+// Translate query text to Go code, or return nil if it should be rendered from
+// template at runtime. It's an error if the query has "IN (...)" bindings since
+// the runtime path can't handle empty slices (see -emptyin).
+func sqlBuilder(r *Renderer, annotMeta *annot.AnnotMeta, inBindings map[string]bool) (*SQLBuilder, error) {
+	ret, err := NewSQLBuilder(r, annotMeta, inBindings)
+	if err != nil {
+		if len(inBindings) != 0 {
+			return nil, fmt.Errorf("%s: query with \"IN (...)\" bindings can't be rendered from template at runtime: %s",
+				annotMeta.FuncName, err)
+		}
+		log.Warnf("%s: query is rendered from template at runtime: %s", annotMeta.FuncName, err)
+		return nil, nil
+	}
+	return ret, nil
+}
+
+// Return true if wrapper function should take a single params struct.
+func useParamsStruct(r *Renderer, annotMeta *annot.AnnotMeta) bool {
+	switch annotMeta.Signature {
+	case "params":
+		return true
+	case "positional":
+		return false
+	default:
+		return r.ParamsStruct
+	}
+}
+
+// Check that each "$optional" arg is of a type whose zero value can be told
+// both in generated code and in runtime template, which treats any struct
+// (e.g. time.Time, sql.NullString) as true.
+func checkOptionals(r *Renderer, annotMeta *annot.AnnotMeta) error {
+	stringArgs := make(map[string]bool)
+	for _, orderBy := range annotMeta.OrderBys {
+		stringArgs[orderBy.Name] = true
+	}
+	for _, optional := range annotMeta.Optionals {
+		name := optional.Name
+		if optional.If != "" {
+			name = optional.If
+		}
+		for _, arg := range annotMeta.Args {
+			if arg.Name != name || arg.Type == "" {
+				continue
+			}
+			if argKind(r.Scopes.CreateTypeNameFromSpec(arg.Type), stringArgs[name]) == "" {
+				return fmt.Errorf("$optional:%s: can't determine whether arg %+q of type %s is zero, "+
+					"use a bool, string, number, slice or pointer type instead", optional.Name, name, arg.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// Check that annotations only for some kind of statements are not misused,
+// stmtType is one of "SELECT"/"INSERT"/"UPDATE"/"DELETE".
+func checkStmtAnnots(annotMeta *annot.AnnotMeta, stmtType string) error {
+	if stmtType != "SELECT" {
+		if annotMeta.Paginate != nil {
+			return fmt.Errorf("$paginate can only be used in SELECT")
+		}
+		if annotMeta.CountMeta != nil {
+			return fmt.Errorf("$page can only be used in SELECT")
+		}
+		if len(annotMeta.OrderBys) != 0 {
+			return fmt.Errorf("$orderBy can only be used in SELECT")
+		}
+	}
+	if stmtType != "INSERT" && annotMeta.Batch != nil {
+		return fmt.Errorf("$batch can only be used in INSERT")
+	}
+	if stmtType == "SELECT" && annotMeta.TxName != "" {
+		return fmt.Errorf("$tx can only be used in INSERT/UPDATE/DELETE")
+	}
+	return nil
+}
+
+// Check return style of INSERT/UPDATE/DELETE, default is rowsAffected.
+func checkExecReturnStyle(annotMeta *annot.AnnotMeta, stmtType string, allowed ...annot.ReturnStyle) error {
+	if annotMeta.ReturnStyle == annot.ReturnUnknown {
+		annotMeta.ReturnStyle = annot.ReturnRowsAffected
+		return nil
+	}
+	for _, style := range allowed {
+		if annotMeta.ReturnStyle == style {
+			return nil
+		}
+	}
+	return fmt.Errorf("Wrapper function's return can't be %+q for %s ", annotMeta.ReturnStyle,
+		stmtType)
+}
+
+// Fill missing argument types with types inferred from query bindings and warn
+// if declared type differs from inferred one. Returns bind metas (nil if can't be
+// inferred).
+func inferArgTypes(r *Renderer, annotMeta *annot.AnnotMeta) ([]*context.BindMeta, error) {
+
+	bindMetas, err := context.NewBindMetas(r.Context, annotMeta)
+	if err != nil {
+		// Inference is best effort.
+		log.Warnf("%s: can't infer bind types: %s", annotMeta.FuncName, err)
+		bindMetas = nil
+	}
+
+	inferred := make(map[string]*TypeName)
+	for _, bindMeta := range bindMetas {
+		if bindMeta.Type == nil {
+			continue
+		}
+		typeName := bindTypeName(r, bindMeta)
+		if prev, ok := inferred[bindMeta.Name]; ok {
+			if !compatibleTypes(prev, typeName) {
+				log.Warnf("%s: bind %+q is used as both %s and %s", annotMeta.FuncName,
+					bindMeta.Name, prev.Spec(), typeName.Spec())
+			}
+			continue
+		}
+		inferred[bindMeta.Name] = typeName
+	}
+
+	for _, arg := range annotMeta.Args {
+		typeName, ok := inferred[arg.Name]
+		if ok && annotMeta.Batch != nil && annotMeta.Batch.HasArg(arg.Name) {
+			// Batch args are bound element by element.
+			typeName = r.Scopes.CreateTypeNameFromSpec("[]" + typeName.Spec())
+		}
+		if arg.Type == "" {
+			if !ok {
+				return nil, fmt.Errorf("%s: can't infer type of arg %+q, please declare it "+
+					"explicitly: \"$arg:%s type:...\"", annotMeta.FuncName, arg.Name, arg.Name)
+			}
+			arg.Type = typeName.Spec()
+			continue
+		}
+		if ok && !compatibleTypes(r.Scopes.CreateTypeNameFromSpec(arg.Type), typeName) {
+			log.Warnf("%s: arg %+q is declared as %s but inferred as %s", annotMeta.FuncName,
+				arg.Name, arg.Type, typeName.Spec())
+		}
+	}
+	return bindMetas, nil
+
+}
+
+// Return names of bindings to be expanded to lists ("IN (:ids)"): bindings of
+// slice args (except []byte), which can't be bound elsewhere.
+func listBindings(r *Renderer, annotMeta *annot.AnnotMeta, bindMetas []*context.BindMeta) (map[string]bool, error) {
+
+	ret := make(map[string]bool)
+	for _, binding := range annotMeta.Bindings {
+		if annotMeta.Batch != nil && annotMeta.Batch.HasArg(binding.Name) {
+			continue
+		}
+		argType := r.Scopes.CreateTypeNameFromSpec(annotMeta.Arg(binding.Name).Type)
+		if argType.IsSlice() && argType.Spec() != "[]byte" && argType.Spec() != "[]uint8" {
+			ret[binding.Name] = true
+		}
+	}
+	for _, bindMeta := range bindMetas {
+		if !ret[bindMeta.Name] {
+			continue
+		}
+		if !bindMeta.InList {
+			return nil, fmt.Errorf("%s: slice arg %+q can only be bound in \"IN (...)\"", annotMeta.FuncName,
+				bindMeta.Name)
+		}
+		// "NOT IN (NULL)" matches no rows while "NOT IN ()" should match all.
+		if bindMeta.NotIn && r.EmptyInNull {
+			return nil, fmt.Errorf("%s: slice arg %+q can't be bound in \"NOT IN (...)\" with -emptyin null",
+				annotMeta.FuncName, bindMeta.Name)
+		}
+	}
+	return ret, nil
+
+}
+
+// Compile every variant of dynamic query to find broken branches.
+func checkVariants(r *Renderer, annotMeta *annot.AnnotMeta) error {
+
+	variants, err := annotMeta.Variants()
+	if err != nil {
+		log.Warnf("%s: skip checking variants of dynamic query: %s", annotMeta.FuncName, err)
+		return nil
+	}
+	if len(variants) <= 1 {
+		return nil
+	}
+
+	for _, variant := range variants {
+		if err := compileQuery(r, variant.Text); err != nil {
+			return fmt.Errorf("%s: query is broken when %s: %s", annotMeta.FuncName,
+				strings.Join(variant.Choices, ", "), err)
+		}
+	}
+	return nil
+
+}
+
+// Compile every variant of the count query of "$page", even if it's static.
+func checkCountQuery(r *Renderer, countMeta *annot.AnnotMeta) error {
+
+	variants, err := countMeta.Variants()
+	if err != nil {
+		log.Warnf("%s: skip checking count query: %s", countMeta.FuncName, err)
+		return nil
+	}
+	for _, variant := range variants {
+		if err := compileQuery(r, variant.Text); err != nil {
+			return fmt.Errorf("%s: count query %+q is broken: %s", countMeta.FuncName, variant.Text, err)
+		}
+	}
+	return nil
+
+}
+
+// Parse and compile a single statement with the embedded db.
+func compileQuery(r *Renderer, text string) error {
+	db := r.Context.DB
+	stmts, err := db.Parse(text)
+	if err != nil {
+		return err
+	}
+	if len(stmts) != 1 {
+		return fmt.Errorf("expect one statement but got %d", len(stmts))
+	}
+	_, err = db.Compile(stmts[0])
+	return err
+}
+
+// Return the Go type of a query binding.
+func bindTypeName(r *Renderer, bindMeta *context.BindMeta) *TypeName {
+	var ret *TypeName
+	if col := bindMeta.Column; col != nil && (col.GoType != "" || boundJSONType(r, col) != nil) {
+		ret = columnTypeName(r, col)
+	} else {
+		ret = r.TypeAdapter.AdaptType(bindMeta.Type)
+	}
+	if bindMeta.InList {
+		list := *ret
+		list.Prefix = "[]" + list.Prefix
+		ret = &list
+	}
+	return ret
+}
+
+// Type spec using package name instead of full package path, used to compare
+// declared types ("sql.NullString") with inferred ones ("database/sql.NullString").
+func shortSpec(typeName *TypeName) string {
+	if typeName.PkgPath == "" {
+		return typeName.Spec()
+	}
+	return typeName.Prefix + path.Base(typeName.PkgPath) + "." + typeName.TypeName
+}
+
+// Kinds of types (by short spec) for checking declared and inferred types.
+var typeKinds = map[string]string{
+	"int": "number", "int8": "number", "int16": "number", "int32": "number", "int64": "number",
+	"uint": "number", "uint8": "number", "uint16": "number", "uint32": "number", "uint64": "number",
+	"float32": "number", "float64": "number", "sql.NullInt64": "number", "sql.NullFloat64": "number",
+	"string": "string", "sql.NullString": "string",
+	"bool": "bool", "sql.NullBool": "bool",
+	"[]byte": "bytes", "[]uint8": "bytes",
+	"time.Time": "time", "mysql.NullTime": "time",
+}
+
+// Return true unless the types are of different kinds, e.g. string and number or
+// slice and scalar. Integer widths, nullability and unknown (e.g. custom) types are
+// not taken into account.
+func compatibleTypes(a, b *TypeName) bool {
+	kind := func(typeName *TypeName) (string, bool) {
+		spec := strings.TrimLeft(shortSpec(typeName), "*")
+		if k, ok := typeKinds[spec]; ok {
+			return k, false
+		}
+		if strings.HasPrefix(spec, "[]") {
+			return typeKinds[strings.TrimLeft(spec[2:], "*")], true
+		}
+		return "", false
+	}
+	ka, sliceA := kind(a)
+	kb, sliceB := kind(b)
+	return sliceA == sliceB && (ka == "" || kb == "" || ka == kb)
+}
+
+// TxFunc is a transaction function executing wrapper functions of consecutive
+// INSERT/UPDATE/DELETE statements annotated by the same "$tx".
+type TxFunc struct {
+	// Transaction function name.
+	Name string
+
+	// Wrapper function names in order.
+	FuncNames []string
+}
+
+func handleTxFunc(r *Renderer, obj interface{}) (interface{}, error) {
+
+	txFunc, ok := obj.(*TxFunc)
+	if !ok {
+		return nil, fmt.Errorf("handleTxFunc: expect *TxFunc but got %T", obj)
+	}
+
+	// Arguments of wrapper functions are shared by name.
+	funcs := []*annot.AnnotMeta{}
+	paramsStruct := make(map[string]bool)
+	args := []*annot.ArgAnnot{}
+	argFuncs := make(map[string]*annot.AnnotMeta)
+	for _, funcName := range txFunc.FuncNames {
+		annotMeta, ok := r.ExecFuncs[funcName]
+		if !ok {
+			return nil, fmt.Errorf("$tx:%s: wrapper function %+q is not rendered", txFunc.Name, funcName)
+		}
+		funcs = append(funcs, annotMeta)
+		paramsStruct[funcName] = useParamsStruct(r, annotMeta)
+
+		for _, arg := range annotMeta.Args {
+			prev, ok := argFuncs[arg.Name]
+			if !ok {
+				args = append(args, arg)
+				argFuncs[arg.Name] = annotMeta
+				continue
+			}
+			prevType := r.Scopes.CreateTypeNameFromSpec(prev.Arg(arg.Name).Type)
+			argType := r.Scopes.CreateTypeNameFromSpec(arg.Type)
+			if shortSpec(prevType) != shortSpec(argType) {
+				return nil, fmt.Errorf("$tx:%s: arg %+q is %s in %s but %s in %s", txFunc.Name, arg.Name,
+					prevType.Spec(), prev.FuncName, argType.Spec(), funcName)
+			}
+		}
+	}
 
 	return map[string]interface{}{
-		"Stmt":  stmtMeta,
-		"Annot": annotMeta,
+		"Name":         txFunc.Name,
+		"Funcs":        funcs,
+		"ParamsStruct": paramsStruct,
+		"Args":         args,
 	}, nil
 
 }
@@ -149,5 +869,6 @@ func init() {
 	RegistType("insert", (*ast.InsertStmt)(nil), handleInsertStmt)
 	RegistType("delete", (*ast.DeleteStmt)(nil), handleDeleteStmt)
 	RegistType("update", (*ast.UpdateStmt)(nil), handleUpdateStmt)
+	RegistType("tx", (*TxFunc)(nil), handleTxFunc)
 	RegistType("standalone", (interface{})(nil), handleStandalone)
 }
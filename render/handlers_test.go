@@ -0,0 +1,368 @@
+package render
+
+import (
+	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
+	"github.com/huangjunwen/JustSQL/context"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
+	ts "github.com/pingcap/tidb/util/types"
+	"testing"
+)
+
+func TestComparableType(t *testing.T) {
+	fmt.Println("TestComparableType")
+	scopes := NewScopes()
+	ta := NewTypeAdapter(scopes)
+
+	for _, c := range []struct {
+		ft     ts.FieldType
+		expect bool
+	}{
+		{ts.FieldType{Tp: mysql.TypeLong, Flag: mysql.NotNullFlag}, true},
+		{ts.FieldType{Tp: mysql.TypeVarchar}, true},
+		{ts.FieldType{Tp: mysql.TypeString, Flag: mysql.NotNullFlag | mysql.BinaryFlag, Flen: 16, Charset: "binary"}, false},
+		{ts.FieldType{Tp: mysql.TypeBlob, Flag: mysql.BinaryFlag, Charset: "binary"}, false},
+		{ts.FieldType{Tp: mysql.TypeJSON, Flag: mysql.NotNullFlag}, false},
+		{ts.FieldType{Tp: mysql.TypeJSON}, false},
+	} {
+		ft := c.ft
+		typeName := ta.AdaptType(&ft)
+		col := &context.ColumnMeta{Type: &ft}
+		if comparableType(typeName, col) != c.expect {
+			t.Errorf("%s: expect comparable to be %v\n", typeName.Spec(), c.expect)
+		}
+	}
+
+	if !comparableType(scopes.CreateTypeNameFromSpec("github.com/google/uuid.UUID"), nil) ||
+		comparableType(scopes.CreateTypeNameFromSpec("[]int"), nil) {
+		t.Errorf("Unexpected comparable of custom types\n")
+	}
+}
+
+func TestKeyExpr(t *testing.T) {
+	fmt.Println("TestKeyExpr")
+	r := &Renderer{Scopes: NewScopes()}
+	r.TypeAdapter = NewTypeAdapter(r.Scopes)
+	keyExpr := buildKeyExpr(r)
+
+	// BINARY(16) primary key (e.g. UUID) is converted to string.
+	binary := &context.ColumnMeta{Name: "id", Type: &ts.FieldType{Tp: mysql.TypeString,
+		Flag: mysql.NotNullFlag | mysql.PriKeyFlag | mysql.BinaryFlag, Flen: 16, Charset: "binary"}}
+	if expr, err := keyExpr(binary, "p_.Id"); err != nil || expr != "string(p_.Id)" {
+		t.Errorf("Unexpected key expr %q %v\n", expr, err)
+	}
+
+	integer := &context.ColumnMeta{Name: "id", Type: &ts.FieldType{Tp: mysql.TypeLong, Flag: mysql.NotNullFlag}}
+	if expr, err := keyExpr(integer, "p_.Id"); err != nil || expr != "p_.Id" {
+		t.Errorf("Unexpected key expr %q %v\n", expr, err)
+	}
+
+	custom := &context.ColumnMeta{Name: "tags", Type: integer.Type, GoType: "[]int"}
+	if _, err := keyExpr(custom, "p_.Tags"); err == nil {
+		t.Errorf("Expect error for non-comparable key column\n")
+	}
+}
+
+func TestCheckOptionals(t *testing.T) {
+	fmt.Println("TestCheckOptionals")
+	r := &Renderer{Scopes: NewScopes()}
+
+	for _, c := range []struct {
+		typ    string
+		expect bool
+	}{
+		{"string", true},
+		{"int64", true},
+		{"*time.Time", true},
+		{"[]int", true},
+		{"time.Time", false},
+		{"database/sql.NullString", false},
+		{"github.com/go-sql-driver/mysql.NullTime", false},
+	} {
+		annotMeta, err := annot.NewAnnotMeta("-- $func:F\n-- $arg:x type:" + c.typ + "\n" +
+			"SELECT * FROM t WHERE /*$optional:x*/x=/*$bind:x*/1/**//*$end*/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkOptionals(r, annotMeta); (err == nil) != c.expect {
+			t.Errorf("%s: unexpected result %v\n", c.typ, err)
+		}
+	}
+
+	// Companion bool arg decides instead.
+	annotMeta, err := annot.NewAnnotMeta("-- $func:F\n-- $arg:x type:time.Time\n-- $arg:hasX type:bool\n" +
+		"SELECT * FROM t WHERE /*$optional:x if:hasX*/x=/*$bind:x*/1/**//*$end*/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkOptionals(r, annotMeta); err != nil {
+		t.Errorf("Unexpected error %v\n", err)
+	}
+}
+
+func TestSQLBuilderFallback(t *testing.T) {
+	fmt.Println("TestSQLBuilderFallback")
+	r := &Renderer{Scopes: NewScopes()}
+
+	annotMeta, err := annot.NewAnnotMeta("-- $func:F\n-- $arg:ids type:[]int\n-- $arg:t type:time.Time\n" +
+		"SELECT * FROM t WHERE id IN (/*$bind:ids*/1/**/) /*$${{ if .t }}*/AND 0/*$${{ end }}*/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without "IN (...)" bindings: rendered from template at runtime.
+	if b, err := sqlBuilder(r, annotMeta, nil); b != nil || err != nil {
+		t.Errorf("Unexpected result %v %v\n", b, err)
+	}
+
+	// With "IN (...)" bindings: empty slices can't be handled at runtime.
+	if _, err := sqlBuilder(r, annotMeta, map[string]bool{"ids": true}); err == nil {
+		t.Errorf("Expect error for \"IN (...)\" bindings in runtime template\n")
+	}
+}
+
+func TestListBindings(t *testing.T) {
+	fmt.Println("TestListBindings")
+	annotMeta, err := annot.NewAnnotMeta("-- $func:F\n-- $arg:ids type:[]int\n-- $arg:b type:[]byte\n" +
+		"SELECT * FROM t WHERE id IN (/*$bind:ids*/1/**/) AND b=/*$bind:b*/''/**/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		inList      bool
+		notIn       bool
+		emptyInNull bool
+		expect      bool
+	}{
+		{true, false, false, true},
+		{true, false, true, true},
+		{true, true, false, true},
+		// "NOT IN (NULL)" matches no rows.
+		{true, true, true, false},
+		// "id = ?, ?, ?" is broken.
+		{false, false, false, false},
+	} {
+		r := &Renderer{Scopes: NewScopes(), EmptyInNull: c.emptyInNull}
+		bindMetas := []*context.BindMeta{
+			{Name: "ids", InList: c.inList, NotIn: c.notIn},
+			{Name: "b"},
+		}
+		ret, err := listBindings(r, annotMeta, bindMetas)
+		if (err == nil) != c.expect {
+			t.Errorf("Unexpected error %v for %+v\n", err, c)
+			continue
+		}
+		if err == nil && (!ret["ids"] || ret["b"]) {
+			t.Errorf("Unexpected list bindings %v for %+v\n", ret, c)
+		}
+	}
+}
+
+func TestCheckStmtAnnots(t *testing.T) {
+	fmt.Println("TestCheckStmtAnnots")
+	for _, c := range []struct {
+		src      string
+		stmtType string
+		expect   bool
+	}{
+		{"-- $paginate by:id\nSELECT * FROM t", "SELECT", true},
+		{"-- $paginate by:id\nDELETE FROM t", "DELETE", false},
+		{"-- $page\nSELECT * FROM t", "SELECT", true},
+		{"UPDATE t SET n=1 ORDER BY /*$orderBy:sort allow:\"id,n\"*/id/**/", "UPDATE", false},
+		{"-- $tx:T\nSELECT * FROM t", "SELECT", false},
+		{"-- $tx:T\nDELETE FROM t", "DELETE", true},
+		{"-- $arg:ids type:[]int\n-- $batch:ids\nINSERT INTO t (id) VALUES (/*$bind:ids*/1/**/)", "INSERT", true},
+	} {
+		annotMeta, err := annot.NewAnnotMeta("-- $func:F\n" + c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkStmtAnnots(annotMeta, c.stmtType); (err == nil) != c.expect {
+			t.Errorf("%q: unexpected result %v\n", c.src, err)
+		}
+		if c.stmtType == "INSERT" {
+			if err := checkStmtAnnots(annotMeta, "UPDATE"); err == nil {
+				t.Errorf("Expect error for $batch in UPDATE\n")
+			}
+		}
+	}
+}
+
+// Renderer with table "user" (id INT PK, gender ENUM) and a builder of
+// "SELECT u.* FROM user u" (wildcard) or "SELECT u.id, u.gender FROM user u".
+func newEnumTestRenderer(t *testing.T) (*Renderer, func(wildcard bool) *context.SelectStmtMeta) {
+	ctx := &context.Context{DBName: "justsql", CachedDBMeta: map[string]*context.DBMeta{}}
+	r := &Renderer{Context: ctx, Scopes: NewScopes()}
+	r.TypeAdapter = NewTypeAdapter(r.Scopes)
+
+	ti := &model.TableInfo{Name: model.NewCIStr("user"), Columns: []*model.ColumnInfo{
+		{Name: model.NewCIStr("id"), Offset: 0, FieldType: ts.FieldType{Tp: mysql.TypeLong,
+			Flag: mysql.NotNullFlag | mysql.PriKeyFlag}},
+		{Name: model.NewCIStr("gender"), Offset: 1, FieldType: ts.FieldType{Tp: mysql.TypeEnum,
+			Flag: mysql.NotNullFlag, Elems: []string{"male", "female"}}},
+	}, Indices: []*model.IndexInfo{{Name: model.NewCIStr("PRIMARY"), Primary: true, Unique: true,
+		Columns: []*model.IndexColumn{{Offset: 0}}}}}
+	db := &context.DBMeta{DBInfo: &model.DBInfo{Name: model.NewCIStr("justsql")}, Name: "justsql",
+		Tables: map[string]*context.TableMeta{}}
+	tm, err := context.NewTableMeta(ctx, db, ti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Tables["user"] = tm
+	ctx.CachedDBMeta["justsql"] = db
+
+	newStmtMeta := func(wildcard bool) *context.SelectStmtMeta {
+		meta := &context.SelectStmtMeta{
+			TableRefs: &context.TableRefsMeta{
+				TableRefNames:   []string{"u"},
+				TableMetas:      []*context.TableMeta{tm},
+				TableRefNameMap: map[string]int{"u": 0},
+			},
+			FieldList: &context.FieldListMeta{},
+		}
+		for _, c := range tm.Columns {
+			rf := &ast.ResultField{Column: c.ColumnInfo, Table: ti, TableAsName: model.NewCIStr("u"),
+				DBName: model.NewCIStr("justsql")}
+			meta.ResultFields = append(meta.ResultFields, &context.ResultFieldMeta{ResultField: rf,
+				Name: c.Name, Type: c.Type})
+			if wildcard {
+				meta.FieldList.ResultFieldToWildcard = append(meta.FieldList.ResultFieldToWildcard, 0)
+			} else {
+				meta.FieldList.ResultFieldToWildcard = append(meta.FieldList.ResultFieldToWildcard, -1)
+			}
+		}
+		if wildcard {
+			meta.FieldList.Wildcards = []context.WildcardMeta{{TableRefName: "u", ResultFieldOffset: 0,
+				ResultFieldNum: len(tm.Columns)}}
+		}
+		return meta
+	}
+	return r, newStmtMeta
+}
+
+func TestPaginateEnumKey(t *testing.T) {
+	fmt.Println("TestPaginateEnumKey")
+	r, newStmtMeta := newEnumTestRenderer(t)
+	for _, c := range []struct {
+		wildcard bool
+		expect   string
+	}{
+		// Encoded from the enum field of table struct.
+		{true, "UserGender"},
+		// Encoded from the field of result struct.
+		{false, "string"},
+	} {
+		annotMeta, err := annot.NewAnnotMeta("-- $func:F\n-- $paginate by:\"u.gender,u.id\"\nSELECT u.* FROM user u")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmtMeta := newStmtMeta(c.wildcard)
+		keys, err := paginateKeyIndices(r, stmtMeta, stmtMeta, annotMeta.Paginate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 2 || keys[0] != 1 || keys[1] != 0 {
+			t.Errorf("Unexpected key indices %v\n", keys)
+		}
+		if typ := annotMeta.Paginate.KeyArgs[0].Type; typ != c.expect {
+			t.Errorf("Expect key type %q but got %q\n", c.expect, typ)
+		}
+	}
+}
+
+func TestPaginateJoin(t *testing.T) {
+	fmt.Println("TestPaginateJoin")
+	r, _ := newEnumTestRenderer(t)
+	tm := r.Context.CachedDBMeta["justsql"].Tables["user"]
+
+	// "SELECT u1.id, u1.gender, u2.id, u2.gender FROM user u1 JOIN user u2".
+	stmtMeta := &context.SelectStmtMeta{
+		TableRefs: &context.TableRefsMeta{
+			TableRefNames:   []string{"u1", "u2"},
+			TableMetas:      []*context.TableMeta{tm, tm},
+			TableRefNameMap: map[string]int{"u1": 0, "u2": 1},
+		},
+		FieldList: &context.FieldListMeta{},
+	}
+	for _, name := range []string{"u1", "u2"} {
+		for _, c := range tm.Columns {
+			rf := &ast.ResultField{Column: c.ColumnInfo, Table: tm.TableInfo, TableAsName: model.NewCIStr(name),
+				DBName: model.NewCIStr("justsql")}
+			stmtMeta.ResultFields = append(stmtMeta.ResultFields, &context.ResultFieldMeta{ResultField: rf,
+				Name: c.Name, Type: c.Type})
+			stmtMeta.FieldList.ResultFieldToWildcard = append(stmtMeta.FieldList.ResultFieldToWildcard, -1)
+		}
+	}
+
+	for _, c := range []struct {
+		by     string
+		expect bool
+	}{
+		// Rows of u1 may be joined with many rows of u2.
+		{"u1.id", false},
+		{"u1.gender,u2.id", false},
+		{"u1.id,u2.id", true},
+	} {
+		annotMeta, err := annot.NewAnnotMeta("-- $func:F\n-- $paginate by:\"" + c.by + "\"\nSELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := paginateKeyIndices(r, stmtMeta, stmtMeta, annotMeta.Paginate); (err == nil) != c.expect {
+			t.Errorf("%q: unexpected result %v\n", c.by, err)
+		}
+	}
+}
+
+func TestMapEnumKey(t *testing.T) {
+	fmt.Println("TestMapEnumKey")
+	r, newStmtMeta := newEnumTestRenderer(t)
+	for _, c := range []struct {
+		wildcard bool
+		expect   string
+	}{
+		// Stored in the enum field of table struct.
+		{true, "UserGender"},
+		// Stored in the field of result struct.
+		{false, "string"},
+	} {
+		stmtMeta := newStmtMeta(c.wildcard)
+		keyType, err := resultFieldTypeName(r, stmtMeta, stmtMeta, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ := keyType.Spec(); typ != c.expect {
+			t.Errorf("Expect key type %q but got %q\n", c.expect, typ)
+		}
+		if !comparableType(keyType, r.Context.ColumnByResultField(stmtMeta.ResultFields[1].ResultField)) {
+			t.Errorf("Expect key type %q to be comparable\n", keyType.Spec())
+		}
+	}
+}
+
+func TestCompatibleTypes(t *testing.T) {
+	fmt.Println("TestCompatibleTypes")
+	scopes := NewScopes()
+	for _, c := range []struct {
+		a, b   string
+		expect bool
+	}{
+		{"int", "int32", true},
+		{"int", "database/sql.NullInt64", true},
+		{"*int64", "uint8", true},
+		{"sql.NullString", "database/sql.NullString", true},
+		{"[]int", "[]int64", true},
+		{"time.Time", "github.com/go-sql-driver/mysql.NullTime", true},
+		{"github.com/google/uuid.UUID", "[]byte", true},
+		{"string", "int32", false},
+		{"[]int", "int32", false},
+		{"[]byte", "string", false},
+		{"[]byte", "[]int", false},
+		{"github.com/google/uuid.UUID", "[]int", false},
+	} {
+		if compatibleTypes(scopes.CreateTypeNameFromSpec(c.a), scopes.CreateTypeNameFromSpec(c.b)) != c.expect {
+			t.Errorf("%s, %s: expect compatible to be %v\n", c.a, c.b, c.expect)
+		}
+	}
+}
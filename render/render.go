@@ -2,6 +2,7 @@ package render
 
 import (
 	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
 	"github.com/huangjunwen/JustSQL/context"
 	"io"
 	"reflect"
@@ -87,6 +88,25 @@ type Renderer struct {
 	// Extra functions used in templates.
 	ExtraFuncs template.FuncMap
 
+	// Default extra struct tags for generated structs. Can be overrided
+	// by table comment or DML annotation.
+	Tags *annot.TagsAnnot
+
+	// Generated DML functions take a single params struct instead of positional
+	// arguments. Can be overrided by DML annotation.
+	ParamsStruct bool
+
+	// Use "NULL" for empty slices in "IN (...)" instead of returning error.
+	EmptyInNull bool
+
+	// Shared result struct name -> signature of its fields. Shared result structs
+	// are declared only once in all DML files.
+	SharedResults map[string]string
+
+	// Wrapper function name -> annotation meta of rendered INSERT/UPDATE/DELETE
+	// statements. Used by transaction functions.
+	ExecFuncs map[string]*annot.AnnotMeta
+
 	// Map type -> (template set name -> template).
 	Templates map[reflect.Type]map[string]*template.Template
 
@@ -107,6 +127,12 @@ func NewRenderer(ctx *context.Context) (*Renderer, error) {
 		Scopes:          NewScopes(),
 		Templates:       make(map[reflect.Type]map[string]*template.Template),
 		TemplateSetName: DefaultTemplateSetName,
+		SharedResults:   make(map[string]string),
+		ExecFuncs:       make(map[string]*annot.AnnotMeta),
+		Tags: &annot.TagsAnnot{
+			Names:  []string{},
+			Naming: "snake",
+		},
 	}
 
 	ret.TypeAdapter = NewTypeAdapter(ret.Scopes)
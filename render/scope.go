@@ -149,12 +149,16 @@ func (scopes *Scopes) CreateTypeName(pkgPath, typeName string) *TypeName {
 }
 
 // Create TypeName from dot-seperated spec:
-//   [pkgPath.]type
+//   [prefix][pkgPath.]type
 // Example:
 //   "[]byte"
 //   "sql.NullString"
 //   "github.com/go-sql-driver/mysql.NullTime"
+//   "[]*github.com/go-sql-driver/mysql.NullTime"
 func (scopes *Scopes) CreateTypeNameFromSpec(s string) *TypeName {
+	prefix := typePrefixRe.FindString(s)
+	s = s[len(prefix):]
+
 	var pkgPath, typeName string
 	i := strings.LastIndex(s, ".")
 	if i < 0 {
@@ -165,9 +169,13 @@ func (scopes *Scopes) CreateTypeNameFromSpec(s string) *TypeName {
 		typeName = s[i+1:]
 	}
 
-	return scopes.CreateTypeName(pkgPath, typeName)
+	ret := scopes.CreateTypeName(pkgPath, typeName)
+	ret.Prefix = prefix
+	return ret
 }
 
+var typePrefixRe *regexp.Regexp = regexp.MustCompile(`^(\[[0-9]*\]|\*)*`)
+
 // PkgName represents a package used in source code.
 type PkgName struct {
 	// In which set of scopes the pkg is declared.
@@ -194,23 +202,31 @@ type TypeName struct {
 
 	// Name of the type.
 	TypeName string
+
+	// Slice/array/pointer prefix of the type, e.g. "[]", "*", "[]*".
+	Prefix string
+}
+
+// IsSlice returns true if the type is a slice type.
+func (n *TypeName) IsSlice() bool {
+	return strings.HasPrefix(n.Prefix, "[]")
 }
 
-// Return "PkgName.TypeName". Note that PkgName is dynamicly determined by
+// Return "[Prefix]PkgName.TypeName". Note that PkgName is dynamicly determined by
 // current scope. See PkgName's doc.
 func (tn *TypeName) String() string {
 	pkgName := tn.PkgName.String()
 	if pkgName == "" {
-		return tn.TypeName
+		return tn.Prefix + tn.TypeName
 	}
-	return fmt.Sprintf("%s.%s", pkgName, tn.TypeName)
+	return fmt.Sprintf("%s%s.%s", tn.Prefix, pkgName, tn.TypeName)
 }
 
 // Spec returns the full (unique) spec of the type name.
 func (tn *TypeName) Spec() string {
 	if tn.PkgName.PkgPath == "" {
-		return tn.TypeName
+		return tn.Prefix + tn.TypeName
 	}
-	return fmt.Sprintf("%s.%s", tn.PkgName.PkgPath, tn.TypeName)
+	return fmt.Sprintf("%s%s.%s", tn.Prefix, tn.PkgName.PkgPath, tn.TypeName)
 
 }
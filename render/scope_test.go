@@ -24,5 +24,8 @@ func TestScopes(t *testing.T) {
 	testCreateTypeNameFromSpec(t, scopes, "github.com/go-sql-driver/mysql.NullTime", "mysql.NullTime")
 	testCreateTypeNameFromSpec(t, scopes, "github.com/pingcap/tidb/mysql.SQLError", "mysql_1.SQLError")
 	testCreateTypeNameFromSpec(t, scopes, "github.com/pingcap/tidb/mysql.dot.SQLError", "mysql_2.SQLError")
+	testCreateTypeNameFromSpec(t, scopes, "[]sql.NullString", "[]sql.NullString")
+	testCreateTypeNameFromSpec(t, scopes, "[]*github.com/go-sql-driver/mysql.NullTime", "[]*mysql.NullTime")
+	testCreateTypeNameFromSpec(t, scopes, "[4]byte", "[4]byte")
 
 }
@@ -0,0 +1,603 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// SQLBuilder contains Go code to build query text and positional args of a
+// wrapper function. It is translated from the processed query text at generation
+// time so that no template execution or named query parsing is needed per call.
+type SQLBuilder struct {
+	// True if the query text is the same for every call.
+	Static bool
+
+	// Query text with "?" placeholders for static query.
+	Query string
+
+	// Positional args (Go expressions) for static query.
+	Args []string
+
+	// For multi-row INSERT ("$batch"): Query/Args are those before the VALUES rows,
+	// BatchRow/BatchRowArgs are those of a row (batch args are indexed by "i_") and
+	// BatchSuffix/BatchSuffixArgs are those after the rows.
+	BatchRow        string
+	BatchRowArgs    []string
+	BatchSuffix     string
+	BatchSuffixArgs []string
+
+	// Go statements writing query text to "buf_" (*bytes.Buffer) and appending
+	// args to "args_" for dynamic query.
+	code string
+}
+
+// Placeholder of error return values in code.
+const errReturnMark = "\x00"
+
+// Code returns Go statements building a dynamic query. errReturn is the return
+// values when error ("err_") occurs.
+func (b *SQLBuilder) Code(errReturn string) string {
+	return strings.Replace(b.code, errReturnMark, errReturn, -1)
+}
+
+// NewSQLBuilder translates the processed query text of annotMeta. Only a subset of
+// substitution blocks are supported: "if"/"else"/"with"/"range" on args with
+// conditions composed by "not"/"and"/"or"/"eq"/"ne"/"lt"/"le"/"gt"/"ge"/"len"
+// and output of string args. Bindings in inBindings are expanded to lists. Returns
+// error if the query can't be translated.
+func NewSQLBuilder(r *Renderer, annotMeta *annot.AnnotMeta, inBindings map[string]bool) (*SQLBuilder, error) {
+
+	t := &sqlTranslator{
+		funcName:    annotMeta.FuncName,
+		argTypes:    make(map[string]*TypeName),
+		bindNames:   make(map[string]bool),
+		stringArgs:  make(map[string]bool),
+		inBindings:  inBindings,
+		emptyInNull: r.EmptyInNull,
+		static:      true,
+	}
+	for _, arg := range annotMeta.Args {
+		if arg.Type == "" {
+			return nil, fmt.Errorf("type of arg %+q is unknown", arg.Name)
+		}
+		t.argTypes[arg.Name] = r.Scopes.CreateTypeNameFromSpec(arg.Type)
+	}
+	for _, binding := range annotMeta.Bindings {
+		t.bindNames[binding.Name] = true
+	}
+	for _, name := range annotMeta.GeneratedBindNames() {
+		t.bindNames[name] = true
+	}
+	for _, orderBy := range annotMeta.OrderBys {
+		t.stringArgs[orderBy.Name] = true
+	}
+
+	if annotMeta.Batch != nil {
+		return t.batch(annotMeta.Batch)
+	}
+
+	tmpl, err := template.New(annotMeta.FuncName).Parse(annotMeta.Text)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Tree != nil {
+		if err := t.node(tmpl.Tree.Root, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.static {
+		return &SQLBuilder{
+			Static: true,
+			Query:  t.text.String(),
+			Args:   t.args,
+		}, nil
+	}
+	t.flush()
+	return &SQLBuilder{
+		code: t.code.String(),
+	}, nil
+
+}
+
+// Translate multi-row INSERT. Query text of it contains no substitution block.
+func (t *sqlTranslator) batch(b *annot.BatchAnnot) (*SQLBuilder, error) {
+
+	queries, args := [3]string{}, [3][]string{}
+	for i, text := range [3]string{b.Prefix, b.Row, b.Suffix} {
+		t.text.Reset()
+		t.args = nil
+		t.queryText(text)
+		queries[i], args[i] = t.text.String(), t.args
+	}
+	if !t.static {
+		return nil, fmt.Errorf("list bindings (\"IN (...)\") are not supported in batch INSERT")
+	}
+	for i, arg := range args[1] {
+		if b.HasArg(arg) {
+			args[1][i] = arg + "[i_]"
+		}
+	}
+	return &SQLBuilder{
+		Static:          true,
+		Query:           queries[0],
+		Args:            args[0],
+		BatchRow:        queries[1],
+		BatchRowArgs:    args[1],
+		BatchSuffix:     queries[2],
+		BatchSuffixArgs: args[2],
+	}, nil
+
+}
+
+type sqlTranslator struct {
+	funcName  string
+	argTypes  map[string]*TypeName
+	bindNames map[string]bool
+
+	// Bindings expanded to lists and whether to use "NULL" for empty lists.
+	inBindings  map[string]bool
+	emptyInNull bool
+
+	// Args of named string types.
+	stringArgs map[string]bool
+
+	// Query text not written yet.
+	text bytes.Buffer
+
+	// Positional args in order, used for static query.
+	args []string
+
+	// Generated code.
+	code   bytes.Buffer
+	indent int
+
+	// False if any control structure is used.
+	static bool
+}
+
+func (t *sqlTranslator) line(format string, a ...interface{}) {
+	t.code.WriteString(strings.Repeat("\t", t.indent+1))
+	fmt.Fprintf(&t.code, format, a...)
+	t.code.WriteString("\n")
+}
+
+// Write pending query text to code.
+func (t *sqlTranslator) flush() {
+	if t.text.Len() == 0 {
+		return
+	}
+	t.line("buf_.WriteString(%+q)", t.text.String())
+	t.text.Reset()
+}
+
+// Enter a control structure.
+func (t *sqlTranslator) enter(format string, a ...interface{}) {
+	t.static = false
+	t.flush()
+	t.line(format, a...)
+	t.indent += 1
+}
+
+// Leave a control structure.
+func (t *sqlTranslator) leave(closing string) {
+	t.flush()
+	t.indent -= 1
+	t.line("%s", closing)
+}
+
+// rebound is true inside "range"/"with" where dot is no longer args.
+func (t *sqlTranslator) node(node parse.Node, rebound bool) error {
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := t.node(child, rebound); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *parse.TextNode:
+		t.queryText(string(n.Text))
+		return nil
+
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 {
+			e, err := t.operand(n.Pipe.Cmds[0].Args[0], rebound)
+			if err == nil && e.kind == "string" && e.arg {
+				t.static = false
+				t.flush()
+				t.line("buf_.WriteString(%s)", e.code)
+				return nil
+			}
+		}
+
+	case *parse.IfNode:
+		cond, err := t.cond(n.Pipe, rebound)
+		if err != nil {
+			return err
+		}
+		return t.branch(cond, n.List, n.ElseList, rebound, rebound)
+
+	case *parse.WithNode:
+		cond, err := t.cond(n.Pipe, rebound)
+		if err != nil {
+			return err
+		}
+		return t.branch(cond, n.List, n.ElseList, true, rebound)
+
+	case *parse.RangeNode:
+		if len(n.Pipe.Decl) != 0 || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+			break
+		}
+		e, err := t.operand(n.Pipe.Cmds[0].Args[0], rebound)
+		if err != nil {
+			return err
+		}
+		if e.kind != "slice" {
+			break
+		}
+		if n.ElseList != nil {
+			t.enter("if len(%s) == 0 {", e.code)
+			if err := t.node(n.ElseList, rebound); err != nil {
+				return err
+			}
+			t.leave("} else {")
+			t.indent += 1
+		}
+		t.enter("for range %s {", e.code)
+		if err := t.node(n.List, true); err != nil {
+			return err
+		}
+		t.leave("}")
+		if n.ElseList != nil {
+			t.indent -= 1
+			t.line("}")
+		}
+		return nil
+
+	}
+	return fmt.Errorf("unsupported %q in substitution block", node.String())
+
+}
+
+func (t *sqlTranslator) branch(cond string, list, elseList *parse.ListNode, listRebound, elseRebound bool) error {
+	t.enter("if %s {", cond)
+	for {
+		if err := t.node(list, listRebound); err != nil {
+			return err
+		}
+		if elseList == nil {
+			break
+		}
+		// "else if" in template.
+		if n, ok := elseList.Nodes[0].(*parse.IfNode); ok && len(elseList.Nodes) == 1 {
+			c, err := t.cond(n.Pipe, elseRebound)
+			if err != nil {
+				return err
+			}
+			t.leave("} else if " + c + " {")
+			t.indent += 1
+			list, elseList, listRebound = n.List, n.ElseList, elseRebound
+			continue
+		}
+		t.leave("} else {")
+		t.indent += 1
+		if err := t.node(elseList, elseRebound); err != nil {
+			return err
+		}
+		break
+	}
+	t.leave("}")
+	return nil
+}
+
+// Query text with named bindings.
+func (t *sqlTranslator) queryText(s string) {
+
+	prefix := annot.BindNamePrefix
+	for {
+		i := strings.Index(s, prefix)
+		if i < 0 {
+			t.text.WriteString(s)
+			return
+		}
+		t.text.WriteString(s[:i])
+		s = s[i+len(prefix):]
+
+		// "::" is an escaped ":" in named query.
+		if prefix == ":" && strings.HasPrefix(s, ":") {
+			t.text.WriteString(":")
+			s = s[1:]
+			continue
+		}
+
+		j := 0
+		for j < len(s) && (s[j] == '_' || s[j] >= '0' && s[j] <= '9' ||
+			s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z') {
+			j += 1
+		}
+		name := s[:j]
+		s = s[j:]
+		if !t.bindNames[name] {
+			t.text.WriteString(prefix + name)
+			continue
+		}
+		t.bind(name)
+	}
+
+}
+
+func (t *sqlTranslator) bind(name string) {
+
+	if !t.inBindings[name] {
+		t.text.WriteString("?")
+		t.args = append(t.args, name)
+		t.line("args_ = append(args_, %s)", name)
+		return
+	}
+
+	// Expand slice to "?, ?, ...".
+	t.enter("if len(%s) == 0 {", name)
+	if t.emptyInNull {
+		t.text.WriteString("NULL")
+		t.leave("} else {")
+		t.indent += 1
+	} else {
+		t.line("err_ := &EmptyInListError{Func: %q, Arg: %q}", t.funcName, name)
+		t.line("return %s", errReturnMark)
+		t.leave("}")
+	}
+	t.enter("for i_, v_ := range %s {", name)
+	t.enter("if i_ != 0 {")
+	t.text.WriteString(", ")
+	t.leave("}")
+	t.text.WriteString("?")
+	t.line("args_ = append(args_, v_)")
+	t.leave("}")
+	if t.emptyInNull {
+		t.indent -= 1
+		t.line("}")
+	}
+
+}
+
+// Translated Go expression.
+type goExpr struct {
+	code string
+
+	// "bool"/"string"/"number"/"slice"/"pointer" or "" if unknown.
+	kind string
+
+	// Type spec if it is an arg.
+	spec string
+	arg  bool
+}
+
+// Translate condition of "if"/"with".
+func (t *sqlTranslator) cond(node parse.Node, rebound bool) (string, error) {
+
+	switch n := node.(type) {
+	case *parse.PipeNode:
+		if len(n.Decl) != 0 || len(n.Cmds) != 1 {
+			break
+		}
+		return t.cond(n.Cmds[0], rebound)
+
+	case *parse.CommandNode:
+		ident, ok := n.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			if len(n.Args) != 1 {
+				break
+			}
+			return t.cond(n.Args[0], rebound)
+		}
+		args := n.Args[1:]
+		switch ident.Ident {
+		case "not":
+			if len(args) != 1 {
+				break
+			}
+			c, err := t.cond(args[0], rebound)
+			if err != nil {
+				return "", err
+			}
+			return "!(" + c + ")", nil
+
+		case "and", "or":
+			if len(args) < 2 {
+				break
+			}
+			op := map[string]string{"and": " && ", "or": " || "}[ident.Ident]
+			cs := []string{}
+			for _, arg := range args {
+				c, err := t.cond(arg, rebound)
+				if err != nil {
+					return "", err
+				}
+				cs = append(cs, c)
+			}
+			return "(" + strings.Join(cs, op) + ")", nil
+
+		default:
+			e, err := t.operand(n, rebound)
+			if err != nil {
+				return "", err
+			}
+			return t.truth(e)
+		}
+
+	default:
+		e, err := t.operand(node, rebound)
+		if err != nil {
+			return "", err
+		}
+		return t.truth(e)
+	}
+	return "", fmt.Errorf("unsupported condition %q in substitution block", node.String())
+
+}
+
+// Template's truth of a value.
+func (t *sqlTranslator) truth(e *goExpr) (string, error) {
+	switch e.kind {
+	case "bool":
+		return e.code, nil
+	case "string":
+		return e.code + ` != ""`, nil
+	case "number":
+		return e.code + " != 0", nil
+	case "slice":
+		return "len(" + e.code + ") != 0", nil
+	case "pointer":
+		return e.code + " != nil", nil
+	}
+	return "", fmt.Errorf("can't determine the truth of %q", e.code)
+}
+
+var compareOps = map[string]string{
+	"eq": "==",
+	"ne": "!=",
+	"lt": "<",
+	"le": "<=",
+	"gt": ">",
+	"ge": ">=",
+}
+
+// Translate operand in condition.
+func (t *sqlTranslator) operand(node parse.Node, rebound bool) (*goExpr, error) {
+
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if rebound || len(n.Ident) != 1 {
+			break
+		}
+		return t.argExpr(n.Ident[0])
+
+	case *parse.VariableNode:
+		if len(n.Ident) != 2 || n.Ident[0] != "$" {
+			break
+		}
+		return t.argExpr(n.Ident[1])
+
+	case *parse.StringNode:
+		return &goExpr{code: strconv.Quote(n.Text), kind: "string"}, nil
+
+	case *parse.NumberNode:
+		return &goExpr{code: n.Text, kind: "number"}, nil
+
+	case *parse.BoolNode:
+		return &goExpr{code: strconv.FormatBool(n.True), kind: "bool"}, nil
+
+	case *parse.PipeNode:
+		if len(n.Decl) != 0 || len(n.Cmds) != 1 {
+			break
+		}
+		return t.operand(n.Cmds[0], rebound)
+
+	case *parse.CommandNode:
+		ident, ok := n.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			if len(n.Args) != 1 {
+				break
+			}
+			return t.operand(n.Args[0], rebound)
+		}
+		args := n.Args[1:]
+		switch ident.Ident {
+		case "len":
+			if len(args) != 1 {
+				break
+			}
+			e, err := t.operand(args[0], rebound)
+			if err != nil {
+				return nil, err
+			}
+			if e.kind != "slice" && e.kind != "string" {
+				break
+			}
+			return &goExpr{code: "len(" + e.code + ")", kind: "number"}, nil
+
+		case "and", "or", "not":
+			c, err := t.cond(n, rebound)
+			if err != nil {
+				return nil, err
+			}
+			return &goExpr{code: c, kind: "bool"}, nil
+
+		default:
+			op, ok := compareOps[ident.Ident]
+			if !ok || len(args) != 2 {
+				break
+			}
+			l, err := t.operand(args[0], rebound)
+			if err != nil {
+				return nil, err
+			}
+			r, err := t.operand(args[1], rebound)
+			if err != nil {
+				return nil, err
+			}
+			if l.kind == "" || l.kind != r.kind || (l.arg && r.arg && l.spec != r.spec) {
+				break
+			}
+			if op != "==" && op != "!=" && l.kind != "string" && l.kind != "number" {
+				break
+			}
+			return &goExpr{code: "(" + l.code + " " + op + " " + r.code + ")", kind: "bool"}, nil
+		}
+
+	}
+	return nil, fmt.Errorf("unsupported %q in substitution block", node.String())
+
+}
+
+// Kind of arg type whose truth can be determined: "bool", "string", "number",
+// "slice", "pointer" or "" for others (e.g. structs).
+func argKind(argType *TypeName, isString bool) string {
+	switch {
+	case isString:
+		return "string"
+	case strings.HasPrefix(argType.Prefix, "["):
+		return "slice"
+	case strings.HasPrefix(argType.Prefix, "*"):
+		return "pointer"
+	case argType.PkgPath != "":
+		return ""
+	}
+	switch argType.TypeName {
+	case "bool":
+		return "bool"
+	case "string":
+		return "string"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32",
+		"uint64", "uintptr", "float32", "float64", "byte", "rune":
+		return "number"
+	}
+	return ""
+}
+
+func (t *sqlTranslator) argExpr(name string) (*goExpr, error) {
+
+	argType, ok := t.argTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("%+q is not an arg", name)
+	}
+
+	return &goExpr{
+		code: name,
+		kind: argKind(argType, t.stringArgs[name]),
+		spec: argType.Spec(),
+		arg:  true,
+	}, nil
+
+}
@@ -0,0 +1,87 @@
+package render
+
+import (
+	"fmt"
+	"github.com/huangjunwen/JustSQL/annot"
+	"strings"
+	"testing"
+)
+
+func TestSQLBuilder(t *testing.T) {
+	fmt.Println("TestSQLBuilder")
+	r := &Renderer{Scopes: NewScopes()}
+
+	newBuilder := func(src string, inBindings map[string]bool) (*SQLBuilder, error) {
+		a, err := annot.NewAnnotMeta(src)
+		if err != nil {
+			t.Fatalf("NewAnnotMeta(%q): %s", src, err)
+		}
+		return NewSQLBuilder(r, a, inBindings)
+	}
+
+	// Static query.
+	b, err := newBuilder("-- $arg:id type:int\n-- $arg:nick type:string\n"+
+		"SELECT * FROM user WHERE id=/*$bind:id*/1/**/ AND nick=/*$bind:nick*/''/**/ AND id=/*$bind:id*/1/**/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Static || b.Query != "SELECT * FROM user WHERE id=? AND nick=? AND id=?" ||
+		strings.Join(b.Args, ",") != "id,nick,id" {
+		t.Errorf("unexpected static builder %#v", b)
+	}
+
+	// Dynamic query.
+	b, err = newBuilder("-- $func:Q\n-- $arg:ids type:[]int\n-- $arg:nick type:string\n-- $arg:p type:*int\n"+
+		"SELECT * FROM user WHERE 1 "+
+		"/*$${{ if and .nick (not .p) }}*/AND nick=/*$bind:nick*/''/**//*$${{ else if gt (len .ids) 1 }}*/AND 0/*$${{ end }}*/"+
+		" AND id IN (/*$bind:ids*/1/**/)", map[string]bool{"ids": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := b.Code("nil, err_")
+	fmt.Println(code)
+	for _, expect := range []string{
+		`if (nick != "" && !(p != nil)) {`,
+		`} else if (len(ids) > 1) {`,
+		`err_ := &EmptyInListError{Func: "Q", Arg: "ids"}`,
+		`return nil, err_`,
+		`for i_, v_ := range ids {`,
+		`args_ = append(args_, v_)`,
+	} {
+		if b.Static || !strings.Contains(code, expect) {
+			t.Errorf("expect %q in code", expect)
+		}
+	}
+
+	// Batch INSERT.
+	b, err = newBuilder("-- $arg:blogId type:int\n-- $arg:names type:[]string\n-- $arg:n type:int\n-- $batch:names\n"+
+		"INSERT INTO tag (blog_id, name) VALUES (/*$bind:blogId*/1/**/, /*$bind:names*/'a'/**/) "+
+		"ON DUPLICATE KEY UPDATE n=/*$bind:n*/1/**/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Query != "INSERT INTO tag (blog_id, name) VALUES " || len(b.Args) != 0 ||
+		b.BatchRow != "(?, ?)" || strings.Join(b.BatchRowArgs, ",") != "blogId,names[i_]" ||
+		b.BatchSuffix != " ON DUPLICATE KEY UPDATE n=?" || strings.Join(b.BatchSuffixArgs, ",") != "n" {
+		t.Errorf("unexpected batch builder %#v", b)
+	}
+
+	if _, err := newBuilder("-- $arg:names type:[]string\n-- $arg:ids type:[]int\n-- $batch:names\n"+
+		"INSERT INTO tag (name, n) VALUES (/*$bind:names*/'a'/**/, (SELECT COUNT(*) FROM t WHERE id IN (/*$bind:ids*/1/**/)))",
+		map[string]bool{"ids": true}); err == nil {
+		t.Errorf("expect error for list binding in batch INSERT")
+	}
+
+	// Unsupported.
+	for _, src := range []string{
+		"-- $arg:t type:time.Time\nSELECT * FROM user WHERE 1 /*$${{ if .t }}*/AND 0/*$${{ end }}*/",
+		"-- $arg:ids type:[]int\nSELECT * FROM user WHERE 1 /*$${{ range .ids }}*/AND /*$${{ . }}*//*$${{ end }}*/",
+		"-- $arg:id type:int\nSELECT * FROM user WHERE id=/*$${{ .id }}*/",
+		"-- $arg:id type:int\nSELECT * FROM user WHERE 1 /*$${{ if printf \"%d\" .id }}*/AND 0/*$${{ end }}*/",
+	} {
+		if _, err := newBuilder(src, nil); err == nil {
+			t.Errorf("expect error for %q", src)
+		}
+	}
+
+}
@@ -10,12 +10,32 @@ import (
 type TypeAdapter struct {
 	*Scopes
 	AllNullTypes bool
+
+	// Map "table.column" -> type spec of the Go type that a JSON column is
+	// (un)marshalled to/from.
+	jsonTypes map[string]string
 }
 
 func NewTypeAdapter(scopes *Scopes) *TypeAdapter {
 	return &TypeAdapter{
-		Scopes: scopes,
+		Scopes:    scopes,
+		jsonTypes: make(map[string]string),
+	}
+}
+
+// BindJSONType binds a JSON column to a Go type (in dot-seperated spec). Values of
+// the column will be (un)marshalled to/from that type instead of json.RawMessage.
+func (ta *TypeAdapter) BindJSONType(tableName, columnName, typeSpec string) {
+	ta.jsonTypes[tableName+"."+columnName] = typeSpec
+}
+
+// JSONType returns the Go type bound to a JSON column or nil if not bound.
+func (ta *TypeAdapter) JSONType(tableName, columnName string) *TypeName {
+	typeSpec, ok := ta.jsonTypes[tableName+"."+columnName]
+	if !ok {
+		return nil
 	}
+	return ta.Scopes.CreateTypeNameFromSpec(typeSpec)
 }
 
 // Main method of TypeAdapter. Find a type suitable to store a db field data.
@@ -32,6 +52,16 @@ func (ta *TypeAdapter) AdaptType(ft *ts.FieldType) *TypeName {
 	unsigned := mysql.HasUnsignedFlag(flag)
 	binary := mysql.HasBinaryFlag(flag)
 
+	// JSON is stored as raw bytes by default. json.RawMessage can't be scanned
+	// from NULL, so NullRawMessage (declared in generated code) is used for
+	// nullable columns.
+	if tp == mysql.TypeJSON {
+		if nullable {
+			return ta.Scopes.CreateTypeName("", "NullRawMessage")
+		}
+		return ta.Scopes.CreateTypeName("encoding/json", "RawMessage")
+	}
+
 	switch cls {
 	case ts.ClassInt:
 		switch tp {
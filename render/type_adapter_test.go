@@ -0,0 +1,24 @@
+package render
+
+import (
+	"fmt"
+	"github.com/pingcap/tidb/mysql"
+	ts "github.com/pingcap/tidb/util/types"
+	"testing"
+)
+
+func TestAdaptJSONType(t *testing.T) {
+	fmt.Println("TestAdaptJSONType")
+	ta := NewTypeAdapter(NewScopes())
+
+	// json.RawMessage can't be scanned from NULL.
+	for flag, expect := range map[uint]string{
+		mysql.NotNullFlag: "encoding/json.RawMessage",
+		0:                 "NullRawMessage",
+	} {
+		typeName := ta.AdaptType(&ts.FieldType{Tp: mysql.TypeJSON, Flag: flag})
+		if typeName.Spec() != expect {
+			t.Errorf("JSON column (flag %d): expect %q but got %q\n", flag, expect, typeName.Spec())
+		}
+	}
+}
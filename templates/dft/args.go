@@ -0,0 +1,32 @@
+package dft
+
+// Shared by templates of DML wrapper functions: copies arguments out of the
+// params/opts struct and applies default values to omitted (zero) ones. Zero
+// values of types not comparable with "==" are told by reflection.
+const argsTemplate = `
+{{- define "args" }}
+{{- $funcName := .Annot.FuncName }}
+{{- $optsName := printf "%sOpts" $funcName }}
+{{- $optArgs := .Annot.OptionalArgs }}
+{{- $paramsStruct := .ParamsStruct }}
+{{- $structArgs := or (and $paramsStruct .Annot.FuncArgs) $optArgs }}
+{{- if $structArgs }}
+
+	// - Arguments.
+{{- if not $paramsStruct }}
+	if opts_ == nil {
+		opts_ = &{{ $optsName }}{}
+	}
+{{- end }}
+{{- range $arg := $structArgs }}
+	{{ $arg.Name }} := {{ if $paramsStruct }}params_{{ else }}opts_{{ end }}.{{ $arg.FieldName }}
+	{{- if ne $arg.Default "" }}
+	{{- $argType := typeName $arg.Type }}
+	{{- $zeroKind := zeroKind $argType }}
+	if {{ if eq $zeroKind "slice" }}len({{ $arg.Name }}) == 0{{ else if eq $zeroKind "comparable" }}{{ $arg.Name }} == *new({{ $argType }}){{ else }}{{ imp "reflect" }}.ValueOf(&{{ $arg.Name }}).Elem().IsZero(){{ end }} {
+		{{ $arg.Name }} = {{ $arg.Default }}
+	}
+	{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}`
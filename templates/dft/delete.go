@@ -5,30 +5,56 @@ import (
 )
 
 func init() {
-	render.RegistBuiltinTemplate("delete", render.DefaultTemplateSetName, `
+	render.RegistBuiltinTemplate("delete", render.DefaultTemplateSetName, argsTemplate+`
 {{/* =========================== */}}
 {{/*          imports            */}}
 {{/* =========================== */}}
 {{- $ctx := imp "context" -}}
 {{- $sqlx := imp "github.com/jmoiron/sqlx" -}}
-{{- $template := imp "text/template" -}}
-{{- $bytes := imp "bytes" -}}
 
 {{/* =========================== */}}
 {{/*          variables          */}}
 {{/* =========================== */}}
 {{- $funcName := .Annot.FuncName -}}
-{{- $hasInBinding := ne (.Annot.Env "hasInBinding") "" -}}
+{{- $optsName := printf "%sOpts" $funcName -}}
+{{- $optArgs := .Annot.OptionalArgs -}}
+{{- $paramsName := printf "%sParams" $funcName -}}
+{{- $paramsStruct := .ParamsStruct -}}
+{{- $returnStyle := .Annot.ReturnStyle -}}
+{{- $zero := or (and (eq $returnStyle "exec") "nil") "0" -}}
+{{- $hasInBinding := .InBindings -}}
 
 {{/* =========================== */}}
 {{/*        main function        */}}
 {{/* =========================== */}}
+{{- if not .SQLBuilder }}
+{{- $template := imp "text/template" }}
 var _{{ $funcName }}SQLTmpl = {{ $template }}.Must({{ $template }}.New({{ printf "%q" $funcName }}).Parse("" +
 {{- range $line := split .Annot.Text "\n" }}
 	{{- $lineSP := printf "%s%s" $line " " }}
 	{{ printf "%+q" $lineSP }} +
 {{- end }}""))
+{{ end }}
 
+{{- if $paramsStruct }}
+// {{ $paramsName }} contains arguments of {{ $funcName }}. Omitted (zero) optional arguments
+// use their default values if any.
+type {{ $paramsName }} struct {
+{{- range $arg := .Annot.Args }}
+	{{ $arg.FieldName }} {{ typeName $arg.Type }} `+"`"+`db:"{{ $arg.Name }}"`+"`"+`
+{{- end }}
+}
+
+{{ else if $optArgs }}
+// {{ $optsName }} contains optional arguments of {{ $funcName }}. Omitted (zero) arguments
+// use their default values if any.
+type {{ $optsName }} struct {
+{{- range $arg := $optArgs }}
+	{{ $arg.FieldName }} {{ typeName $arg.Type }}
+{{- end }}
+}
+
+{{ end -}}
 // {{ $funcName }} is generated from:
 //
 {{- range $line := split .Stmt.DeleteStmt.Text "\n" }}
@@ -37,7 +63,29 @@ var _{{ $funcName }}SQLTmpl = {{ $template }}.Must({{ $template }}.New({{ printf
 	{{- end }}
 {{- end }}
 //
-func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Annot.Args }}, {{ $arg.Name }} {{ typeName $arg.Type }} {{ end }}) (int64, error) {
+func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ if $paramsStruct }}, params_ {{ $paramsName }}{{ else }}{{ range $arg := .Annot.RequiredArgs }}, {{ $arg.Name }} {{ typeName $arg.Type }} {{ end }}{{ if $optArgs }}, opts_ *{{ $optsName }}{{ end }}{{ end }}) ({{ if eq $returnStyle "exec" }}{{ imp "database/sql" }}.Result{{ else }}int64{{ end }}, error) {
+
+{{- template "args" . }}
+
+{{- if .SQLBuilder }}
+{{- if .SQLBuilder.Static }}
+
+	// - Query and args.
+	query_ := {{ $sqlx }}.Rebind(BindType, "" +
+{{- range $line := split .SQLBuilder.Query "\n" }}
+	{{- $lineSP := printf "%s%s" $line " " }}
+		{{ printf "%+q" $lineSP }} +
+{{- end }}"")
+	args_ := []interface{}{ {{- join .SQLBuilder.Args ", " -}} }
+{{- else }}
+
+	// - Build query and args.
+	buf_ := new({{ imp "bytes" }}.Buffer)
+	args_ := []interface{}{}
+{{ .SQLBuilder.Code (printf "%s, err_" $zero) }}
+	query_ := {{ $sqlx }}.Rebind(BindType, buf_.String())
+{{- end }}
+{{- else }}
 
 	// - Dot object for template and query parameter.
 	dot_ := map[string]interface{}{
@@ -47,34 +95,41 @@ func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Annot.Ar
 	}
 
 	// - Render from template.
-	buf_ := new({{ $bytes }}.Buffer)
+	buf_ := new({{ imp "bytes" }}.Buffer)
 	if err_ := _{{ $funcName }}SQLTmpl.Execute(buf_, dot_); err_ != nil {
-		return 0, err_
+		return {{ $zero }}, err_
 	}
 
 	// - Handle named query.
 	query_, args_, err_ := {{ $sqlx }}.Named(buf_.String(), dot_)
 	if err_ != nil {
-		return 0, err_
+		return {{ $zero }}, err_
 	}
 
 {{ if $hasInBinding -}}
 	// - Handle "IN (?)".
 	query_, args_, err_ = {{ $sqlx }}.In(query_, args_...)
 	if err_ != nil {
-		return 0, err_
+		return {{ $zero }}, err_
 	}
 {{- end }}
 
 	// - Rebind.
 	query_ = {{ $sqlx }}.Rebind(BindType, query_)
+{{- end }}
 
 	// - Execute.
 	res_, err_ := db_.ExecContext(ctx_, query_, args_...)
 	if err_ != nil {
-		return 0, err_
+		return {{ $zero }}, err_
 	}
+{{- if eq $returnStyle "exec" }}
+	return res_, nil
+{{- else if eq $returnStyle "lastInsertId" }}
+	return res_.LastInsertId()
+{{- else }}
 	return res_.RowsAffected()
+{{- end }}
 	
 }
 
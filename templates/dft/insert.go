@@ -5,22 +5,47 @@ import (
 )
 
 func init() {
-	render.RegistBuiltinTemplate("insert", render.DefaultTemplateSetName, `
+	render.RegistBuiltinTemplate("insert", render.DefaultTemplateSetName, argsTemplate+`
 {{/* =========================== */}}
 {{/*          imports            */}}
 {{/* =========================== */}}
 {{- $ctx := imp "context" -}}
 {{- $sqlx := imp "github.com/jmoiron/sqlx" -}}
-{{- $template := imp "text/template" -}}
 
 {{/* =========================== */}}
 {{/*          variables          */}}
 {{/* =========================== */}}
 {{- $funcName := .Annot.FuncName -}}
+{{- $optsName := printf "%sOpts" $funcName -}}
+{{- $optArgs := .Annot.OptionalArgs -}}
+{{- $paramsName := printf "%sParams" $funcName -}}
+{{- $paramsStruct := .ParamsStruct -}}
+{{- $returnStyle := .Annot.ReturnStyle -}}
+{{- $zero := or (and (eq $returnStyle "exec") "nil") "0" -}}
+{{- $batch := .Annot.Batch -}}
 
 {{/* =========================== */}}
 {{/*        main function        */}}
 {{/* =========================== */}}
+{{- if $paramsStruct }}
+// {{ $paramsName }} contains arguments of {{ $funcName }}. Omitted (zero) optional arguments
+// use their default values if any.
+type {{ $paramsName }} struct {
+{{- range $arg := .Annot.Args }}
+	{{ $arg.FieldName }} {{ typeName $arg.Type }} `+"`"+`db:"{{ $arg.Name }}"`+"`"+`
+{{- end }}
+}
+
+{{ else if $optArgs }}
+// {{ $optsName }} contains optional arguments of {{ $funcName }}. Omitted (zero) arguments
+// use their default values if any.
+type {{ $optsName }} struct {
+{{- range $arg := $optArgs }}
+	{{ $arg.FieldName }} {{ typeName $arg.Type }}
+{{- end }}
+}
+
+{{ end -}}
 // {{ $funcName }} is generated from:
 //
 {{- range $line := split .Stmt.InsertStmt.Text "\n" }}
@@ -29,13 +54,100 @@ func init() {
 	{{- end }}
 {{- end }}
 //
-func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Annot.Args }}, {{ $arg.Name }} {{ typeName $arg.Type }} {{ end }}) (int64, error) {
+func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ if $paramsStruct }}, params_ {{ $paramsName }}{{ else }}{{ range $arg := .Annot.RequiredArgs }}, {{ $arg.Name }} {{ typeName $arg.Type }} {{ end }}{{ if $optArgs }}, opts_ *{{ $optsName }}{{ end }}{{ end }}) ({{ if $batch }}int64, int64{{ else if eq $returnStyle "exec" }}{{ imp "database/sql" }}.Result{{ else }}int64{{ end }}, error) {
+
+{{- if not .SQLBuilder }}
 
 	const sql_ = "" +
 {{- range $line := split .Annot.Text "\n" }}
 	{{- $lineSP := printf "%s%s" $line " " }}
 		{{ printf "%+q" $lineSP }} +
 {{- end }}""
+{{- end }}
+
+{{- template "args" . }}
+
+{{- if $batch }}
+{{- $builder := .SQLBuilder }}
+{{- $firstArg := index $batch.Args 0 }}
+
+	// - Batch args should have the same length.
+	n_ := len({{ $firstArg }})
+{{- range $i, $name := $batch.Args }}
+{{- if $i }}
+	if len({{ $name }}) != n_ {
+		return 0, 0, {{ imp "fmt" }}.Errorf("{{ $funcName }}: batch arg %q has %d elements but %q has %d",
+			{{ printf "%q" $name }}, len({{ $name }}), {{ printf "%q" $firstArg }}, n_)
+	}
+{{- end }}
+{{- end }}
+
+	// - Rows per query so that placeholders are no more than MaxPlaceholders.
+	rows_ := (MaxPlaceholders{{ with len $builder.Args }} - {{ . }}{{ end }}{{ with len $builder.BatchSuffixArgs }} - {{ . }}{{ end }}) / {{ len $builder.BatchRowArgs }}
+	if rows_ < 1 {
+		rows_ = 1
+	}
+
+	firstId_, rowsAffected_ := int64(0), int64(0)
+	for start_ := 0; start_ < n_; start_ += rows_ {
+		end_ := start_ + rows_
+		if end_ > n_ {
+			end_ = n_
+		}
+
+		// - Build query and args.
+		buf_ := new({{ imp "bytes" }}.Buffer)
+		buf_.WriteString({{ printf "%+q" $builder.Query }})
+		args_ := []interface{}{ {{- join $builder.Args ", " -}} }
+		for i_ := start_; i_ < end_; i_++ {
+			if i_ != start_ {
+				buf_.WriteString(", ")
+			}
+			buf_.WriteString({{ printf "%+q" $builder.BatchRow }})
+			args_ = append(args_, {{ join $builder.BatchRowArgs ", " }})
+		}
+		buf_.WriteString({{ printf "%+q" $builder.BatchSuffix }})
+{{- if $builder.BatchSuffixArgs }}
+		args_ = append(args_, {{ join $builder.BatchSuffixArgs ", " }})
+{{- end }}
+
+		// - Execute.
+		res_, err_ := db_.ExecContext(ctx_, {{ $sqlx }}.Rebind(BindType, buf_.String()), args_...)
+		if err_ != nil {
+			return firstId_, rowsAffected_, err_
+		}
+		if start_ == 0 {
+			if firstId_, err_ = res_.LastInsertId(); err_ != nil {
+				return firstId_, rowsAffected_, err_
+			}
+		}
+		cnt_, err_ := res_.RowsAffected()
+		if err_ != nil {
+			return firstId_, rowsAffected_, err_
+		}
+		rowsAffected_ += cnt_
+	}
+	return firstId_, rowsAffected_, nil
+{{- else }}
+{{- if .SQLBuilder }}
+{{- if .SQLBuilder.Static }}
+
+	// - Query and args.
+	query_ := {{ $sqlx }}.Rebind(BindType, "" +
+{{- range $line := split .SQLBuilder.Query "\n" }}
+	{{- $lineSP := printf "%s%s" $line " " }}
+		{{ printf "%+q" $lineSP }} +
+{{- end }}"")
+	args_ := []interface{}{ {{- join .SQLBuilder.Args ", " -}} }
+{{- else }}
+
+	// - Build query and args.
+	buf_ := new({{ imp "bytes" }}.Buffer)
+	args_ := []interface{}{}
+{{ .SQLBuilder.Code (printf "%s, err_" $zero) }}
+	query_ := {{ $sqlx }}.Rebind(BindType, buf_.String())
+{{- end }}
+{{- else }}
 
 	// - Dot object for query parameter.
 	dot_ := map[string]interface{}{
@@ -47,17 +159,25 @@ func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Annot.Ar
 	// - Handle named query.
 	query_, args_, err_ := {{ $sqlx }}.Named(sql_, dot_)
 	if err_ != nil {
-		return 0, err_
+		return {{ $zero }}, err_
 	}
 
 	query_ = {{ $sqlx }}.Rebind(BindType, query_)
+{{- end }}
 
 	// - Execute.
 	res_, err_ := db_.ExecContext(ctx_, query_, args_...)
 	if err_ != nil {
-		return 0, err_
+		return {{ $zero }}, err_
 	}
+{{- if eq $returnStyle "exec" }}
+	return res_, nil
+{{- else if eq $returnStyle "lastInsertId" }}
+	return res_.LastInsertId()
+{{- else }}
 	return res_.RowsAffected()
+{{- end }}
+{{- end }}
 	
 }
 
@@ -5,69 +5,117 @@ import (
 )
 
 func init() {
-	render.RegistBuiltinTemplate("select", render.DefaultTemplateSetName, `
+	render.RegistBuiltinTemplate("select", render.DefaultTemplateSetName, argsTemplate+`
 {{/* =========================== */}}
 {{/*          imports            */}}
 {{/* =========================== */}}
 {{- $ctx := imp "context" -}}
 {{- $sql := imp "database/sql" -}}
 {{- $sqlx := imp "github.com/jmoiron/sqlx" -}}
-{{- $template := imp "text/template" -}}
-{{- $bytes := imp "bytes" -}}
 
 {{/* =========================== */}}
 {{/*          variables          */}}
 {{/* =========================== */}}
 {{- $funcName := .Annot.FuncName -}}
+{{- $optsName := printf "%sOpts" $funcName -}}
+{{- $optArgs := .Annot.OptionalArgs -}}
+{{- $paramsName := printf "%sParams" $funcName -}}
+{{- $paramsStruct := .ParamsStruct -}}
 {{- $rfs := .Stmt.ResultFields -}}
-{{- $retName := printf "%sResult" .Annot.FuncName -}}
-{{- $hasInBinding := ne (.Annot.Env "hasInBinding") "" -}}
+{{- $retName := or .UnwrapName .Annot.ResultName (printf "%sResult" .Annot.FuncName) -}}
+{{- $newRet := or (and .UnwrapName (printf "new(%s)" .UnwrapName)) (printf "new%s()" $retName) -}}
+{{- $hasInBinding := .InBindings -}}
 {{- $returnStyle := .Annot.ReturnStyle -}}
+{{- $isColumnStyle := or (eq $returnStyle "scalar") (eq $returnStyle "column") -}}
+{{- $mapKeyIndex := .MapKeyIndex -}}
+{{- $paginate := .Annot.Paginate -}}
+{{- $page := .Annot.CountMeta -}}
+{{- $errReturn := or (and (eq $returnStyle "scalar") "ret_, err_") (and (eq $returnStyle "each") "err_") (and $paginate "nil, \"\", err_") (and $page "nil, 0, err_") "nil, err_" -}}
 
 {{- $retFieldNameList := uniqueStringList (fn "pascal") "NoNameField" -}}
-{{- $retFieldTypeList := stringList -}}
+{{- $retFieldTypeList := list -}}
+{{- $retFieldSpecList := stringList -}}
+{{- $retFieldTagList := stringList -}}
 {{- $retStructFieldNameList := stringList -}}
 {{- $retStructFieldTypeList := stringList -}}
 {{- $retFieldNameFlattenList := stringList -}}
 {{- range $i, $rf := $rfs -}}
 	{{- $wildcardTableRefName := $.OriginStmt.FieldList.WildcardTableRefName $i -}}
 	{{- $wildcardTable := $.OriginStmt.TableRefs.TableMeta $wildcardTableRefName -}}
-	{{/* Only when this result field is in a wildcard table and the table is in current database */}}
+	{{/* Only when this result field is in a wildcard table and the table is in current database (and not skipped) */}}
 	{{- if notNil $wildcardTable -}}
-		{{- if eq $wildcardTable.DB.Name (dbname) -}}
+		{{- if and (eq $wildcardTable.DB.Name (dbname)) (not $wildcardTable.Skip) -}}
 			{{- $wildcardColumnOffset := $.OriginStmt.FieldList.WildcardColumnOffset $i -}}
 			{{- if eq $wildcardColumnOffset 0 -}}
 				{{- append $retFieldNameList $wildcardTableRefName -}}
 				{{- append $retFieldTypeList (printf "*%s" $wildcardTable.PascalName) -}}
+				{{- append $retFieldSpecList (last $retFieldTypeList) -}}
+				{{- append $retFieldTagList (tags $.Tags $wildcardTableRefName) -}}
 				{{- append $retStructFieldNameList (last $retFieldNameList) -}}
 				{{- append $retStructFieldTypeList $wildcardTable.PascalName -}}
 			{{- end -}}
-			{{- append $retFieldNameFlattenList (printf "%s.%s" (last $retFieldNameList) (index $wildcardTable.Columns $wildcardColumnOffset).PascalName) }}
+			{{- if $.UnwrapName }}
+				{{- append $retFieldNameFlattenList (index $wildcardTable.Columns $wildcardColumnOffset).PascalName }}
+			{{- else }}
+				{{- append $retFieldNameFlattenList (printf "%s.%s" (last $retFieldNameList) (index $wildcardTable.Columns $wildcardColumnOffset).PascalName) }}
+			{{- end }}
 		{{- else -}}
 			{{- append $retFieldNameList $rf.Name -}}
-			{{- append $retFieldTypeList (typeName $rf.Type) -}}
+			{{- append $retFieldTypeList (typeName $rf) -}}
+			{{- append $retFieldSpecList (typeName $rf).Spec -}}
+			{{- append $retFieldTagList (tags $.Tags $rf) -}}
 			{{- append $retFieldNameFlattenList (last $retFieldNameList) }}
 		{{- end -}}
 	{{- else -}}
 		{{- append $retFieldNameList $rf.Name -}}
-		{{- append $retFieldTypeList (typeName $rf.Type) -}}
+		{{- append $retFieldTypeList (typeName $rf) -}}
+		{{- append $retFieldSpecList (typeName $rf).Spec -}}
+		{{- append $retFieldTagList (tags $.Tags $rf) -}}
 		{{- append $retFieldNameFlattenList (last $retFieldNameList) }}
 	{{- end -}}
 {{- end -}}
 {{- $retFieldNames := $retFieldNameList.Strings -}}
-{{- $retFieldTypes := $retFieldTypeList.Strings -}}
+{{- $retFieldTypes := $retFieldTypeList.Items -}}
+{{- $retFieldSpecs := $retFieldSpecList.Strings -}}
+{{- $retFieldTags := $retFieldTagList.Strings -}}
 {{- $retStructFieldNames := $retStructFieldNameList.Strings -}}
 {{- $retStructFieldTypes := $retStructFieldTypeList.Strings -}}
 {{- $retFieldNamesFlatten := $retFieldNameFlattenList.Strings -}}
 
+{{- /* Signature of result struct for sharing, built from type specs so that
+no import is marked as used before the struct is actually emitted */ -}}
+{{- $retSigList := stringList -}}
+{{- if .Group -}}
+	{{- append $retSigList (printf "%s *%s %s" (pascal .Group.Parent.TableRefName) .Group.Parent.Table.PascalName (tags $.Tags .Group.Parent.TableRefName)) -}}
+	{{- range $child := .Group.Children -}}
+		{{- append $retSigList (printf "%s []*%s %s" (pascal $child.TableRefName) $child.Table.PascalName (tags $.Tags $child.TableRefName)) -}}
+	{{- end -}}
+{{- else -}}
+	{{- range $i, $name := $retFieldNames -}}
+		{{- append $retSigList (printf "%s %s %s" $name (index $retFieldSpecs $i) (index $retFieldTags $i)) -}}
+	{{- end -}}
+{{- end -}}
+{{- $declareResult := and (not $isColumnStyle) (not .UnwrapName) (declareResult .Annot.ResultName (join $retSigList "; ")) -}}
+
 {{/* =========================== */}}
 {{/*          return type        */}}
 {{/* =========================== */}}
+{{- if not $declareResult }}
+{{- else if .Group }}
+
+// {{ $retName }} is the return type of {{ $funcName }}, rows are grouped by {{ .Group.Parent.TableRefName }}.
+type {{ $retName }} struct {
+	{{ pascal .Group.Parent.TableRefName }} *{{ .Group.Parent.Table.PascalName }}{{ with tags $.Tags .Group.Parent.TableRefName }} `+"`{{ . }}`"+`{{ end }}
+{{- range $child := .Group.Children }}
+	{{ pascal $child.TableRefName }} []*{{ $child.Table.PascalName }}{{ with tags $.Tags $child.TableRefName }} `+"`{{ . }}`"+`{{ end }}
+{{- end }}
+}
+{{- else }}
 
 // {{ $retName }} is the return type of {{ $funcName }}.
 type {{ $retName }} struct {
 {{- range $i, $name := $retFieldNames }}
-	{{ $name }} {{ index $retFieldTypes $i }}
+	{{ $name }} {{ index $retFieldTypes $i }}{{ with index $retFieldTags $i }} `+"`{{ . }}`"+`{{ end }}
 {{- end }}
 }
 
@@ -78,16 +126,61 @@ func new{{ $retName }}() *{{ $retName }} {
 {{ end -}}
 	}
 }
+{{- end }}
 
 {{/* =========================== */}}
 {{/*        main function        */}}
 {{/* =========================== */}}
+{{- if not .SQLBuilder }}
+{{- $template := imp "text/template" }}
 var _{{ $funcName }}SQLTmpl = {{ $template }}.Must({{ $template }}.New({{ printf "%q" $funcName }}).Parse("" +
 {{- range $line := split .Annot.Text "\n" }}
 	{{- $lineSP := printf "%s%s" $line " " }}
 	{{ printf "%+q" $lineSP }} +
 {{- end }}""))
+{{ end }}
+
+{{- if and $page (not .CountSQLBuilder) }}
+{{- $template := imp "text/template" }}
+var _{{ $funcName }}CountSQLTmpl = {{ $template }}.Must({{ $template }}.New({{ printf "%q" $funcName }}).Parse("" +
+{{- range $line := split $page.Text "\n" }}
+	{{- $lineSP := printf "%s%s" $line " " }}
+	{{ printf "%+q" $lineSP }} +
+{{- end }}""))
+{{ end }}
+
+{{- range $orderBy := .Annot.OrderBys }}
+// {{ $orderBy.TypeName }} is a sort key of {{ $funcName }}.
+type {{ $orderBy.TypeName }} string
+
+// Sort keys of {{ $funcName }}. Zero value means {{ printf "%q" $orderBy.Default }}.
+const (
+{{- range $key := $orderBy.Allow }}
+	{{ $orderBy.TypeName }}{{ $orderBy.KeyName $key }} {{ $orderBy.TypeName }} = {{ printf "%q" $key }}
+{{- end }}
+)
+
+{{ end }}
+
+{{- if $paramsStruct }}
+// {{ $paramsName }} contains arguments of {{ $funcName }}. Omitted (zero) optional arguments
+// use their default values if any.
+type {{ $paramsName }} struct {
+{{- range $arg := .Annot.FuncArgs }}
+	{{ $arg.FieldName }} {{ typeName $arg.Type }} `+"`"+`db:"{{ $arg.Name }}"`+"`"+`
+{{- end }}
+}
+
+{{ else if $optArgs }}
+// {{ $optsName }} contains optional arguments of {{ $funcName }}. Omitted (zero) arguments
+// use their default values if any.
+type {{ $optsName }} struct {
+{{- range $arg := $optArgs }}
+	{{ $arg.FieldName }} {{ typeName $arg.Type }}
+{{- end }}
+}
 
+{{ end -}}
 // {{ $funcName }} is generated from:
 //
 {{- range $line := split .OriginStmt.SelectStmt.Text "\n" }}
@@ -96,7 +189,46 @@ var _{{ $funcName }}SQLTmpl = {{ $template }}.Must({{ $template }}.New({{ printf
 	{{- end }}
 {{- end }}
 //
-func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Annot.Args }}, {{ $arg.Name }} {{ typeName $arg.Type }} {{ end }}) ({{ if eq $returnStyle "one" }}*{{ $retName }}{{ else if eq $returnStyle "many" }}[]*{{ $retName }}{{ end }}, error) {
+func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ if $paramsStruct }}, params_ {{ $paramsName }}{{ else }}{{ range $arg := .Annot.RequiredArgs }}, {{ $arg.Name }} {{ typeName $arg.Type }} {{ end }}{{ if $optArgs }}, opts_ *{{ $optsName }}{{ end }}{{ end }}{{ if eq $returnStyle "each" }}, fn_ func(*{{ $retName }}) error{{ end }}) {{ if eq $returnStyle "each" }}error{{ else }}({{ if eq $returnStyle "one" }}*{{ $retName }}{{ else if eq $returnStyle "many" }}[]*{{ $retName }}{{ if $paginate }}, string{{ else if $page }}, int64{{ end }}{{ else if eq $returnStyle "scalar" }}{{ typeName (index $rfs 0) }}{{ else if eq $returnStyle "column" }}[]{{ typeName (index $rfs 0) }}{{ else if eq $returnStyle "map" }}map[{{ .MapKeyType }}]{{ if .Annot.MapMulti }}[]{{ end }}*{{ $retName }}{{ end }}, error){{ end }} {
+{{- if eq $returnStyle "scalar" }}
+
+	var ret_ {{ typeName (index $rfs 0) }}
+{{- end }}
+
+{{- template "args" . }}
+
+{{- if $paginate }}
+
+	// - Decode key values of the last row of previous page from cursor.
+{{- range $arg := $paginate.KeyArgs }}
+	var {{ $arg.Name }} {{ typeName $arg.Type }}
+{{- end }}
+	if cursor != "" {
+		if err_ := DecodeCursor(cursor{{ range $arg := $paginate.KeyArgs }}, &{{ $arg.Name }}{{ end }}); err_ != nil {
+			return {{ $errReturn }}
+		}
+	}
+{{- end }}
+
+{{- if .SQLBuilder }}
+{{- if .SQLBuilder.Static }}
+
+	// - Query and args.
+	query_ := {{ $sqlx }}.Rebind(BindType, "" +
+{{- range $line := split .SQLBuilder.Query "\n" }}
+	{{- $lineSP := printf "%s%s" $line " " }}
+		{{ printf "%+q" $lineSP }} +
+{{- end }}"")
+	args_ := []interface{}{ {{- join .SQLBuilder.Args ", " -}} }
+{{- else }}
+
+	// - Build query and args.
+	buf_ := new({{ imp "bytes" }}.Buffer)
+	args_ := []interface{}{}
+{{ .SQLBuilder.Code $errReturn }}
+	query_ := {{ $sqlx }}.Rebind(BindType, buf_.String())
+{{- end }}
+{{- else }}
 
 	// - Dot object for template and query parameter.
 	dot_ := map[string]interface{}{
@@ -106,34 +238,117 @@ func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Annot.Ar
 	}
 
 	// - Render from template.
-	buf_ := new({{ $bytes }}.Buffer)
+	buf_ := new({{ imp "bytes" }}.Buffer)
 	if err_ := _{{ $funcName }}SQLTmpl.Execute(buf_, dot_); err_ != nil {
-		return nil, err_
+		return {{ $errReturn }}
 	}
 
 	// - Handle named query.
 	query_, args_, err_ := {{ $sqlx }}.Named(buf_.String(), dot_)
 	if err_ != nil {
-		return nil, err_
+		return {{ $errReturn }}
 	}
 
 {{ if $hasInBinding -}}
 	// - Handle "IN (?)".
 	query_, args_, err_ = {{ $sqlx }}.In(query_, args_...)
 	if err_ != nil {
-		return nil, err_
+		return {{ $errReturn }}
 	}
 {{- end }}
 
 	// - Rebind.
 	query_ = {{ $sqlx }}.Rebind(BindType, query_)
+{{- end }}
+
+{{ if .Group -}}
+	{{- $group := .Group }}
+	{{- $parent := $group.Parent }}
+	// - Query.
+	rows_, err_ := db_.QueryContext(ctx_, query_, args_...)
+	if err_ != nil {
+		return nil, err_
+	}
+	defer rows_.Close()
+
+	// - Scan and group by primary key of {{ $parent.TableRefName }}.
+	ret_ := make([]*{{ $retName }}, 0)
+	parents_ := make(map[[{{ len $parent.KeyColumns }}]interface{}]*{{ $retName }})
+{{- range $j, $child := $group.Children }}
+	seen{{ $j }}_ := make(map[[2]interface{}]bool)
+{{- end }}
+	for rows_.Next() {
+		p_ := new({{ $parent.Table.PascalName }})
+{{- range $i, $rf := $rfs }}
+	{{- $j := $group.ChildIndex ($.OriginStmt.FieldList.WildcardTableRefName $i) }}
+	{{- if ge $j 0 }}
+		{{- $col := index (index $group.Children $j).Table.Columns ($.OriginStmt.FieldList.WildcardColumnOffset $i) }}
+		var c{{ $j }}{{ $col.PascalName }}_ *{{ fieldType $col }}
+	{{- end }}
+{{- end }}
+		if err_ := rows_.Scan(
+{{- range $i, $rf := $rfs }}
+	{{- if ne $i 0 }}, {{ end }}
+	{{- $j := $group.ChildIndex ($.OriginStmt.FieldList.WildcardTableRefName $i) }}
+	{{- $offset := $.OriginStmt.FieldList.WildcardColumnOffset $i }}
+	{{- if ge $j 0 -}}
+		&c{{ $j }}{{ (index (index $group.Children $j).Table.Columns $offset).PascalName }}_
+	{{- else -}}
+		&p_.{{ (index $parent.Table.Columns $offset).PascalName }}
+	{{- end }}
+{{- end }}); err_ != nil {
+			return nil, err_
+		}
+
+		key_ := [{{ len $parent.KeyColumns }}]interface{}{ {{- range $k, $col := $parent.KeyColumns }}{{ if ne $k 0 }}, {{ end }}{{ keyExpr $col (printf "p_.%s" $col.PascalName) }}{{ end -}} }
+		r_, ok_ := parents_[key_]
+		if !ok_ {
+			r_ = &{{ $retName }}{
+				{{ pascal $parent.TableRefName }}: p_,
+{{- range $child := $group.Children }}
+				{{ pascal $child.TableRefName }}: make([]*{{ $child.Table.PascalName }}, 0),
+{{- end }}
+			}
+			parents_[key_] = r_
+			ret_ = append(ret_, r_)
+		}
+{{- range $j, $child := $group.Children }}
+
+		// - Child {{ $child.TableRefName }}, all primary key columns are NULL if not matched.
+		if {{ range $k, $col := $child.KeyColumns }}{{ if ne $k 0 }} && {{ end }}c{{ $j }}{{ $col.PascalName }}_ != nil{{ end }} {
+			ckey_ := [2]interface{}{key_, [{{ len $child.KeyColumns }}]interface{}{ {{- range $k, $col := $child.KeyColumns }}{{ if ne $k 0 }}, {{ end }}{{ keyExpr $col (printf "*c%d%s_" $j $col.PascalName) }}{{ end -}} }}
+			if !seen{{ $j }}_[ckey_] {
+				seen{{ $j }}_[ckey_] = true
+				c_ := new({{ $child.Table.PascalName }})
+	{{- range $col := $child.Table.Columns }}
+				if c{{ $j }}{{ $col.PascalName }}_ != nil {
+					c_.{{ $col.PascalName }} = *c{{ $j }}{{ $col.PascalName }}_
+				}
+	{{- end }}
+				r_.{{ pascal $child.TableRefName }} = append(r_.{{ pascal $child.TableRefName }}, c_)
+			}
+		}
+{{- end }}
+	}
+
+	if err_ := rows_.Err(); err_ != nil {
+		return nil, err_
+	}
 
 {{ if eq $returnStyle "one" -}}
+	if len(ret_) == 0 {
+		return nil, nil
+	}
+	return ret_[0], nil
+{{- else -}}
+	return ret_, nil
+{{- end }}
+{{- else if eq $returnStyle "one" -}}
 	// - Query.
 	row_ := db_.QueryRowContext(ctx_, query_, args_...)
 
 	// - Scan.
-	ret_ := new{{ $retName }}()
+	ret_ := {{ $newRet }}
 	if err_ := row_.Scan({{ range $i, $name := $retFieldNamesFlatten }}{{ if ne $i 0 }}, {{ end }}&ret_.{{ $name }}{{ end }}); err_ != nil {
 		if err_ == {{ $sql }}.ErrNoRows {
 			return nil, nil
@@ -146,20 +361,161 @@ func {{ $funcName }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Annot.Ar
 	// - Query.
 	rows_, err_ := db_.QueryContext(ctx_, query_, args_...)
 	if err_ != nil {
-		return nil, err_
+		return {{ $errReturn }}
 	}
 	defer rows_.Close()
 
 	// - Scan.
 	ret_ := make([]*{{ $retName }}, 0)
 	for rows_.Next() {
-		r_ := new{{ $retName }}()
+		r_ := {{ $newRet }}
 		if err_ := rows_.Scan({{ range $i, $name := $retFieldNamesFlatten }}{{ if ne $i 0 }}, {{ end }}&r_.{{ $name }}{{ end }}); err_ != nil {
-			return nil, err_
+			return {{ $errReturn }}
 		}
 		ret_ = append(ret_, r_)
 	}
 	
+	if err_ := rows_.Err(); err_ != nil {
+		return {{ $errReturn }}
+	}
+{{- if $paginate }}
+
+	// - Cursor of the next page, empty if this is the last one.
+	next_ := ""
+	if len(ret_) != 0 && len(ret_) == limit {
+		last_ := ret_[len(ret_)-1]
+		if next_, err_ = EncodeCursor({{ range $i, $k := .PaginateKeys }}{{ if ne $i 0 }}, {{ end }}last_.{{ index $retFieldNamesFlatten $k }}{{ end }}); err_ != nil {
+			return {{ $errReturn }}
+		}
+	}
+
+	return ret_, next_, nil
+{{- else if $page }}
+
+	// - Count total rows.
+	var total_ int64
+	{
+{{- if .CountSQLBuilder }}
+{{- if .CountSQLBuilder.Static }}
+		query_ := {{ $sqlx }}.Rebind(BindType, "" +
+{{- range $line := split .CountSQLBuilder.Query "\n" }}
+	{{- $lineSP := printf "%s%s" $line " " }}
+			{{ printf "%+q" $lineSP }} +
+{{- end }}"")
+		args_ := []interface{}{ {{- join .CountSQLBuilder.Args ", " -}} }
+{{- else }}
+		buf_ := new({{ imp "bytes" }}.Buffer)
+		args_ := []interface{}{}
+{{ .CountSQLBuilder.Code $errReturn }}
+		query_ := {{ $sqlx }}.Rebind(BindType, buf_.String())
+{{- end }}
+{{- else }}
+{{- if .SQLBuilder }}
+		dot_ := map[string]interface{}{
+{{- range $arg := .Annot.Args }}
+			{{ printf "%q" $arg.Name }}: {{ $arg.Name }},
+{{- end }}
+		}
+{{- end }}
+		buf_ := new({{ imp "bytes" }}.Buffer)
+		if err_ := _{{ $funcName }}CountSQLTmpl.Execute(buf_, dot_); err_ != nil {
+			return {{ $errReturn }}
+		}
+		query_, args_, err_ := {{ $sqlx }}.Named(buf_.String(), dot_)
+		if err_ != nil {
+			return {{ $errReturn }}
+		}
+{{- if $hasInBinding }}
+		query_, args_, err_ = {{ $sqlx }}.In(query_, args_...)
+		if err_ != nil {
+			return {{ $errReturn }}
+		}
+{{- end }}
+		query_ = {{ $sqlx }}.Rebind(BindType, query_)
+{{- end }}
+		if err_ := db_.QueryRowContext(ctx_, query_, args_...).Scan(&total_); err_ != nil {
+			return {{ $errReturn }}
+		}
+	}
+
+	return ret_, total_, nil
+{{- else }}
+
+	return ret_, nil
+{{- end }}
+
+{{- else if eq $returnStyle "map" -}}
+	// - Query.
+	rows_, err_ := db_.QueryContext(ctx_, query_, args_...)
+	if err_ != nil {
+		return nil, err_
+	}
+	defer rows_.Close()
+
+	// - Scan.
+	ret_ := make(map[{{ .MapKeyType }}]{{ if .Annot.MapMulti }}[]{{ end }}*{{ $retName }})
+	for rows_.Next() {
+		r_ := {{ $newRet }}
+		if err_ := rows_.Scan({{ range $i, $name := $retFieldNamesFlatten }}{{ if ne $i 0 }}, {{ end }}&r_.{{ $name }}{{ end }}); err_ != nil {
+			return nil, err_
+		}
+		{{- if .Annot.MapMulti }}
+		ret_[r_.{{ index $retFieldNamesFlatten $mapKeyIndex }}] = append(ret_[r_.{{ index $retFieldNamesFlatten $mapKeyIndex }}], r_)
+		{{- else }}
+		ret_[r_.{{ index $retFieldNamesFlatten $mapKeyIndex }}] = r_
+		{{- end }}
+	}
+
+	if err_ := rows_.Err(); err_ != nil {
+		return nil, err_
+	}
+
+	return ret_, nil
+{{- else if eq $returnStyle "each" -}}
+	// - Query.
+	rows_, err_ := db_.QueryContext(ctx_, query_, args_...)
+	if err_ != nil {
+		return err_
+	}
+	defer rows_.Close()
+
+	// - Scan and call fn_ for each row, stop at the first error.
+	for rows_.Next() {
+		r_ := {{ $newRet }}
+		if err_ := rows_.Scan({{ range $i, $name := $retFieldNamesFlatten }}{{ if ne $i 0 }}, {{ end }}&r_.{{ $name }}{{ end }}); err_ != nil {
+			return err_
+		}
+		if err_ := fn_(r_); err_ != nil {
+			return err_
+		}
+	}
+
+	return rows_.Err()
+{{- else if eq $returnStyle "scalar" -}}
+	// - Query and scan.
+	if err_ := db_.QueryRowContext(ctx_, query_, args_...).Scan(&ret_); err_ != nil {
+		return ret_, err_
+	}
+
+	return ret_, nil
+{{- else if eq $returnStyle "column" -}}
+	// - Query.
+	rows_, err_ := db_.QueryContext(ctx_, query_, args_...)
+	if err_ != nil {
+		return nil, err_
+	}
+	defer rows_.Close()
+
+	// - Scan.
+	ret_ := make([]{{ typeName (index $rfs 0) }}, 0)
+	for rows_.Next() {
+		var r_ {{ typeName (index $rfs 0) }}
+		if err_ := rows_.Scan(&r_); err_ != nil {
+			return nil, err_
+		}
+		ret_ = append(ret_, r_)
+	}
+
 	if err_ := rows_.Err(); err_ != nil {
 		return nil, err_
 	}
@@ -16,12 +16,107 @@ func init() {
 {{- $sql := imp "database/sql" -}}
 {{- $driver := imp "database/sql/driver" -}}
 {{- $sqlx := imp "github.com/jmoiron/sqlx" -}}
+{{- $json := imp "encoding/json" -}}
+{{- $base64 := imp "encoding/base64" -}}
 
 // Global variables.
 var (
 	BindType int
+
+	// Max number of placeholders in a query of batch INSERT.
+	MaxPlaceholders = 65535
 )
 
+// EmptyInListError is returned when an empty slice is bound in "IN (...)".
+type EmptyInListError struct {
+	// Wrapper function name.
+	Func string
+
+	// Argument name.
+	Arg string
+}
+
+func (e *EmptyInListError) Error() string {
+	return {{ $fmt }}.Sprintf("%s: argument %q is an empty slice used in IN (...)", e.Func, e.Arg)
+}
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded.
+var ErrInvalidCursor = {{ $errors }}.New("Invalid cursor")
+
+// EncodeCursor encodes key values of the last row of a page to an opaque cursor.
+func EncodeCursor(keys ...interface{}) (string, error) {
+	data, err := {{ $json }}.Marshal(keys)
+	if err != nil {
+		return "", err
+	}
+	return {{ $base64 }}.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a cursor returned by EncodeCursor into pointers of key values.
+func DecodeCursor(cursor string, keys ...interface{}) error {
+	data, err := {{ $base64 }}.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	raws := []{{ $json }}.RawMessage{}
+	if err := {{ $json }}.Unmarshal(data, &raws); err != nil || len(raws) != len(keys) {
+		return ErrInvalidCursor
+	}
+	for i, raw := range raws {
+		if err := {{ $json }}.Unmarshal(raw, keys[i]); err != nil {
+			return ErrInvalidCursor
+		}
+	}
+	return nil
+}
+
+// NullRawMessage is a nullable JSON value stored as raw bytes.
+type NullRawMessage struct {
+	{{ $json }}.RawMessage
+	Valid bool // NULL if Valid is false.
+}
+
+// Scan implements database/sql.Scanner interface.
+func (m *NullRawMessage) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		m.RawMessage, m.Valid = nil, false
+	case []byte:
+		m.RawMessage, m.Valid = append({{ $json }}.RawMessage(nil), v...), true
+	case string:
+		m.RawMessage, m.Valid = {{ $json }}.RawMessage(v), true
+	default:
+		return {{ $fmt }}.Errorf("Unexpected value for NullRawMessage: %T", value)
+	}
+	return nil
+}
+
+// Value implements database/sql/driver.Valuer interface.
+func (m NullRawMessage) Value() ({{ $driver }}.Value, error) {
+	if !m.Valid {
+		return nil, nil
+	}
+	return []byte(m.RawMessage), nil
+}
+
+// MarshalJSON implements encoding/json.Marshaler interface.
+func (m NullRawMessage) MarshalJSON() ([]byte, error) {
+	if !m.Valid {
+		return []byte("null"), nil
+	}
+	return m.RawMessage, nil
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler interface.
+func (m *NullRawMessage) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		m.RawMessage, m.Valid = nil, false
+		return nil
+	}
+	m.RawMessage, m.Valid = append({{ $json }}.RawMessage(nil), data...), true
+	return nil
+}
+
 // SetBindType set the bind type for SQL.
 func SetBindType(driverName string) {
 	BindType = {{ $sqlx }}.BindType(driverName)
@@ -34,6 +129,11 @@ type DBer interface {
 	QueryRowContext({{ $ctx }}.Context, string, ...interface{}) *{{ $sql }}.Row
 }
 
+// TxBeginner for *sql.DB
+type TxBeginner interface {
+	BeginTx({{ $ctx }}.Context, *{{ $sql }}.TxOptions) (*{{ $sql }}.Tx, error)
+}
+
 // IsValueValid return true if value is not 'NULL'
 func IsValueValid(value interface{}) bool {
 	switch val := value.(type) {
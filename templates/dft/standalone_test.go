@@ -0,0 +1,104 @@
+package dft
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/huangjunwen/JustSQL/context"
+	"github.com/huangjunwen/JustSQL/render"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+const sqlxPkgPath = "github.com/jmoiron/sqlx"
+
+// Importer of standard packages from source, sqlx (not required for tests) is
+// imported as an empty package.
+type stdImporter struct {
+	types.Importer
+}
+
+func (i stdImporter) Import(path string) (*types.Package, error) {
+	if path == sqlxPkgPath {
+		pkg := types.NewPackage(path, "sqlx")
+		pkg.MarkComplete()
+		return pkg, nil
+	}
+	return i.Importer.Import(path)
+}
+
+// Render standalone code and type check it.
+func checkStandalone(t *testing.T) *types.Package {
+	r, err := render.NewRenderer(&context.Context{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Scopes.SwitchScope("justsql.go")
+	var body bytes.Buffer
+	if err := r.Render(nil, &body); err != nil {
+		t.Fatal(err)
+	}
+	var src bytes.Buffer
+	src.WriteString("package x\n\nimport (\n")
+	for _, pkg := range r.Scopes.CurrScope().ListPkg() {
+		fmt.Fprintf(&src, "\t%s %q\n", pkg[1], pkg[0])
+	}
+	src.WriteString(")\n")
+	src.Write(body.Bytes())
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "justsql.go", src.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{
+		Importer: stdImporter{importer.ForCompiler(fset, "source", nil)},
+		Error: func(err error) {
+			if !strings.Contains(err.Error(), "sqlx.") {
+				t.Error(err)
+			}
+		},
+	}
+	pkg, _ := conf.Check("x", fset, []*ast.File{file}, nil)
+	return pkg
+}
+
+func TestNullRawMessage(t *testing.T) {
+	fmt.Println("TestNullRawMessage")
+	pkg := checkStandalone(t)
+	obj := pkg.Scope().Lookup("NullRawMessage")
+	if obj == nil {
+		t.Fatal("NullRawMessage is not declared")
+	}
+	typ := obj.Type()
+	ptr := types.NewPointer(typ)
+
+	iface := func(pkgPath, name string) *types.Interface {
+		for _, imp := range pkg.Imports() {
+			if imp.Path() == pkgPath {
+				return imp.Scope().Lookup(name).Type().Underlying().(*types.Interface)
+			}
+		}
+		t.Fatalf("%s is not imported", pkgPath)
+		return nil
+	}
+
+	// Scanned from NULL and used as query args.
+	if !types.Implements(ptr, iface("database/sql", "Scanner")) {
+		t.Error("*NullRawMessage does not implement sql.Scanner")
+	}
+	if !types.Implements(typ, iface("database/sql/driver", "Valuer")) {
+		t.Error("NullRawMessage does not implement driver.Valuer")
+	}
+	// Marshaled as "null" when not valid.
+	if !types.Implements(typ, iface("encoding/json", "Marshaler")) {
+		t.Error("NullRawMessage does not implement json.Marshaler")
+	}
+	if !types.Implements(ptr, iface("encoding/json", "Unmarshaler")) {
+		t.Error("*NullRawMessage does not implement json.Unmarshaler")
+	}
+}
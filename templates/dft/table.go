@@ -13,6 +13,7 @@ func init() {
 {{- $fmt := imp "fmt" -}}
 {{- $sql := imp "database/sql" -}}
 {{- $driver := imp "database/sql/driver" -}}
+{{- $json := imp "encoding/json" -}}
 {{- $sqlx := imp "github.com/jmoiron/sqlx" -}}
 {{- $strings := imp "strings" -}}
 
@@ -29,9 +30,15 @@ func init() {
 {{- $structFieldTypeList := stringList -}}
 {{- $enumColList := columnList -}}
 {{- $setColList := columnList -}}
+{{- $jsonColList := columnList -}}
 {{- range $i, $col := $cols -}}
 	{{- append $structFieldNameList $col.PascalName -}}
-	{{- if $col.IsEnum -}}
+	{{- if notNil (jsonType $col) -}}
+		{{- append $structFieldTypeList (printf "%s%s" $structName $col.PascalName) -}}
+		{{- append $jsonColList $col -}}
+	{{- else if ne $col.GoType "" -}}
+		{{- append $structFieldTypeList (typeName $col) -}}
+	{{- else if $col.IsEnum -}}
 		{{- append $structFieldTypeList (printf "%s%s" $structName $col.PascalName) -}}
 		{{- append $enumColList $col -}}
 	{{- else if $col.IsSet -}}
@@ -45,6 +52,7 @@ func init() {
 {{- $structFieldTypes := $structFieldTypeList.Strings -}}
 {{- $enumCols := $enumColList.Cols -}}
 {{- $setCols := $setColList.Cols -}}
+{{- $jsonCols := $jsonColList.Cols -}}
 
 {{/* =========================== */}}
 {{/*          enums              */}}
@@ -70,6 +78,10 @@ func init() {
 	{{/* =========================== */}}
 
 // Enum {{ $enumName }}.
+{{- if ne $col.Comment "" }}
+//
+{{ comment $col.Comment }}
+{{- end }}
 type {{ $enumName }} int
 
 // Enum {{ $enumName }} items.
@@ -150,6 +162,10 @@ func (e {{ $enumName }}) Value() ({{ $driver }}.Value, error) {
 	{{/* =========================== */}}
 
 // Set {{ $setName }}.
+{{- if ne $col.Comment "" }}
+//
+{{ comment $col.Comment }}
+{{- end }}
 type {{ $setName }} struct {
 	val uint64 // Up to 64 distinct members. See https://dev.mysql.com/doc/refman/5.7/en/set.html
 	valid bool // NULL if valid is false.
@@ -225,14 +241,84 @@ func (s {{ $setName }}) Value() (driver.Value, error) {
 
 {{- end }}
 
+{{/* =========================== */}}
+{{/*          json               */}}
+{{/* =========================== */}}
+
+{{ range $i, $col := $jsonCols -}}
+
+	{{/* =========================== */}}
+	{{/*        json variables       */}}
+	{{/* =========================== */}}
+	{{- $jsonName := printf "%s%s" $structName $col.PascalName -}}
+	{{- $dataType := jsonType $col -}}
+
+	{{/* =========================== */}}
+	{{/*        json code            */}}
+	{{/* =========================== */}}
+
+// {{ $jsonName }} stores JSON column "{{ $col.Name }}" as {{ $dataType }}.
+{{- if ne $col.Comment "" }}
+//
+{{ comment $col.Comment }}
+{{- end }}
+type {{ $jsonName }} struct {
+	Data {{ $dataType }}
+	Valid bool // NULL if Valid is false.
+}
+
+// Scan implements database/sql.Scanner interface.
+func (j *{{ $jsonName }}) Scan(value interface{}) error {
+	var data {{ $dataType }}
+	if value == nil {
+		j.Data = data
+		j.Valid = false
+		return nil
+	}
+
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return {{ $fmt }}.Errorf("Unexpected value for {{ $jsonName }}: %T", value)
+	}
+
+	if err := {{ $json }}.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	j.Data = data
+	j.Valid = true
+	return nil
+}
+
+// Value implements database/sql/driver.Valuer interface.
+func (j {{ $jsonName }}) Value() ({{ $driver }}.Value, error) {
+	if !j.Valid {
+		return nil, nil
+	}
+	return {{ $json }}.Marshal(j.Data)
+}
+
+{{- end }}
+
 {{/* =========================== */}}
 {{/*          main struct        */}}
 {{/* =========================== */}}
 
 // {{ $structName }} represents an entry of table "{{ $tableName }}".
+{{- if ne .Table.Comment "" }}
+//
+{{ comment .Table.Comment }}
+{{- end }}
 type {{ $structName }} struct {
 {{- range $i, $col := $cols }}
-	{{ index $structFieldNames $i }} {{ index $structFieldTypes $i }} `+"`db:\"{{ $col.Name }}\"`"+` // {{ $col.Name }}
+	{{- if ne $col.Comment "" }}
+	{{ comment $col.Comment }}
+	{{- end }}
+	{{ index $structFieldNames $i }} {{ index $structFieldTypes $i }} `+"`db:\"{{ $col.Name }}\"{{ with tags $.Tags $col }} {{ . }}{{ end }}`"+` // {{ $col.Name }}
 {{- end }}
 }
 
@@ -240,6 +326,8 @@ type {{ $structName }} struct {
 {{/*     insert/update/delete    */}}
 {{/* =========================== */}}
 
+{{ if not .Table.ReadOnly -}}
+
 // Insert insert an entry of {{ $tableName }} into database.
 func (entry_ *{{ $structName }}) Insert(ctx_ {{ $ctx }}.Context, db_ DBer) error {
 
@@ -264,6 +352,70 @@ func (entry_ *{{ $structName }}) Insert(ctx_ {{ $ctx }}.Context, db_ DBer) error
 	{{ end -}}
 }
 
+// BatchInsert{{ $structName }} inserts entries of {{ $tableName }} into database by multi-row INSERT,
+// each query has no more than MaxPlaceholders placeholders. Returns the {{ if notNil $autoIncCol }}auto increment
+// id of the first entry (LastInsertId of the first query) and {{ end }}number of rows affected.
+{{- if notNil $autoIncCol }}
+//
+// Auto increment ids are filled into entries assuming ids generated by one query are
+// consecutive from its LastInsertId. InnoDB guarantees this for such "simple inserts"
+// in any innodb_autoinc_lock_mode, but not if some entries already have non-zero ids
+// ("mixed-mode inserts") or auto_increment_increment is not 1.
+{{- end }}
+func BatchInsert{{ $structName }}(ctx_ {{ $ctx }}.Context, db_ DBer, entries_ []*{{ $structName }}) ({{ if notNil $autoIncCol }}int64, {{ end }}int64, error) {
+
+	rows_ := MaxPlaceholders / {{ len $cols }}
+	if rows_ < 1 {
+		rows_ = 1
+	}
+
+	{{ if notNil $autoIncCol }}firstId_ := int64(0)
+	{{ end -}}
+	rowsAffected_ := int64(0)
+	for start_ := 0; start_ < len(entries_); start_ += rows_ {
+		end_ := start_ + rows_
+		if end_ > len(entries_) {
+			end_ = len(entries_)
+		}
+
+		buf_ := new({{ imp "bytes" }}.Buffer)
+		buf_.WriteString("INSERT INTO {{ $tableName }} ({{ join (columnNames $cols) ", " }}) VALUES ")
+		args_ := make([]interface{}, 0, (end_-start_)*{{ len $cols }})
+		for i_, entry_ := range entries_[start_:end_] {
+			if i_ != 0 {
+				buf_.WriteString(", ")
+			}
+			buf_.WriteString("({{ join (dup "?" (len $cols))  ", " }})")
+			args_ = append(args_{{ range $i, $field := $structFieldNames }}, entry_.{{ $field }}{{ end }})
+		}
+
+		res_, err_ := db_.ExecContext(ctx_, {{ $sqlx }}.Rebind(BindType, buf_.String()), args_...)
+		if err_ != nil {
+			return {{ if notNil $autoIncCol }}firstId_, {{ end }}rowsAffected_, err_
+		}
+		{{- if notNil $autoIncCol }}
+		id_, err_ := res_.LastInsertId()
+		if err_ != nil {
+			return firstId_, rowsAffected_, err_
+		}
+		if start_ == 0 {
+			firstId_ = id_
+		}
+		for i_, entry_ := range entries_[start_:end_] {
+			if err_ := SaveCoerceFromInt64(id_+int64(i_), &entry_.{{ $autoIncCol.PascalName }}); err_ != nil {
+				return firstId_, rowsAffected_, err_
+			}
+		}
+		{{- end }}
+		cnt_, err_ := res_.RowsAffected()
+		if err_ != nil {
+			return {{ if notNil $autoIncCol }}firstId_, {{ end }}rowsAffected_, err_
+		}
+		rowsAffected_ += cnt_
+	}
+	return {{ if notNil $autoIncCol }}firstId_, {{ end }}rowsAffected_, nil
+}
+
 {{ if ne (len $primaryCols) 0 -}}
 
 func (entry_ *{{ $structName }}) Update(ctx_ {{ $ctx }}.Context, db_ DBer) (int64, error) {
@@ -294,6 +446,8 @@ func (entry_ *{{ $structName }}) Delete(ctx_ {{ $ctx }}.Context, db_ DBer) (int6
 	return r_.RowsAffected()
 }
 
+{{ end -}}
+
 {{ end }}
 
 {{/* =========================== */}}
@@ -302,7 +456,7 @@ func (entry_ *{{ $structName }}) Delete(ctx_ {{ $ctx }}.Context, db_ DBer) (int6
 
 {{- range $i, $fk := .Table.ForeignKeys }}
 	{{- $refIndex := $fk.RefIndex }}
-	{{- if $refIndex.Unique }}
+	{{- if and $refIndex.Unique (not $fk.RefTable.Skip) }}
 	{{/* =========================== */}}
 	{{/*    foreign key variables    */}}
 	{{/* =========================== */}}
@@ -342,7 +496,7 @@ func (entry_ *{{ $structName }}) {{ $refTable.PascalName }}(ctx_ {{ $ctx }}.Cont
 		{{- $argTypeList := stringList }}
 		{{- range $j, $col := $indexCols }}
 			{{- append $argNameList (camel $col.Name) }}
-			{{- append $argTypeList (typeName $col.Type) }}
+			{{- append $argTypeList (typeName $col) }}
 		{{- end }}
 		{{- $argNames := $argNameList.Strings }}
 		{{- $argTypes := $argTypeList.Strings }}
@@ -375,6 +529,76 @@ func {{ $structName }}By{{ $index.PascalName }}(ctx_ {{ $ctx }}.Context, db_ DBe
 	{{- end }}
 {{- end }}
 
+{{/* =========================== */}}
+{{/*         extra finders       */}}
+{{/* =========================== */}}
+
+{{- range $i, $finder := .Table.Finders }}
+	{{/* =========================== */}}
+	{{/*     finder variables        */}}
+	{{/* =========================== */}}
+	{{- $finderCols := $finder.Columns }}
+	{{- $argNameList := stringList }}
+	{{- $argTypeList := stringList }}
+	{{- range $j, $col := $finderCols }}
+		{{- append $argNameList (camel $col.Name) }}
+		{{- append $argTypeList (typeName $col) }}
+	{{- end }}
+	{{- $argNames := $argNameList.Strings }}
+	{{- $argTypes := $argTypeList.Strings }}
+
+	{{/* =========================== */}}
+	{{/*        finder code          */}}
+	{{/* =========================== */}}
+
+// {{ $structName }}{{ $finder.Name }} query {{ printf "%+q" $tableName }} table by {{ join (columnNames $finderCols) ", " }}.
+{{- if $finder.ReturnOne }}
+// Return nil if error occurred or there is not row found.
+{{- end }}
+func {{ $structName }}{{ $finder.Name }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $j, $argName := $argNames }}, {{ $argName }} {{ index $argTypes $j }}{{ end }}) ({{ if $finder.ReturnOne }}*{{ $structName }}{{ else }}[]*{{ $structName }}{{ end }}, error) {
+
+	sql_ := {{ $sqlx }}.Rebind(BindType, "SELECT {{ join (columnNames $cols) ", " }} " +
+		"FROM {{ $tableName }} " +
+		"WHERE {{ range $j, $col := $finderCols }}{{ if ne $j 0 }}AND {{ end }}{{ $col.Name }}=? {{ end }}")
+
+{{ if $finder.ReturnOne -}}
+	row_ := db_.QueryRowContext(ctx_, sql_{{ range $j, $argName := $argNames }}, {{ $argName }}{{ end }})
+
+	entry_ := new({{ $structName }})
+	if err_ := row_.Scan({{ range $j, $field := $structFieldNames }}{{ if ne $j 0 }}, {{ end }}&entry_.{{ $field }}{{ end }}); err_ != nil {
+		if err_ == {{ $sql }}.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err_
+	}
+
+	return entry_, nil
+{{- else -}}
+	rows_, err_ := db_.QueryContext(ctx_, sql_{{ range $j, $argName := $argNames }}, {{ $argName }}{{ end }})
+	if err_ != nil {
+		return nil, err_
+	}
+	defer rows_.Close()
+
+	ret_ := make([]*{{ $structName }}, 0)
+	for rows_.Next() {
+		entry_ := new({{ $structName }})
+		if err_ := rows_.Scan({{ range $j, $field := $structFieldNames }}{{ if ne $j 0 }}, {{ end }}&entry_.{{ $field }}{{ end }}); err_ != nil {
+			return nil, err_
+		}
+		ret_ = append(ret_, entry_)
+	}
+
+	if err_ := rows_.Err(); err_ != nil {
+		return nil, err_
+	}
+
+	return ret_, nil
+{{- end }}
+}
+
+{{- end }}
+
 `)
 
 }
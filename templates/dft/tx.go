@@ -0,0 +1,80 @@
+package dft
+
+import (
+	"github.com/huangjunwen/JustSQL/render"
+)
+
+func init() {
+	render.RegistBuiltinTemplate("tx", render.DefaultTemplateSetName, `
+{{/* =========================== */}}
+{{/*          imports            */}}
+{{/* =========================== */}}
+{{- $ctx := imp "context" -}}
+
+{{/* =========================== */}}
+{{/*          variables          */}}
+{{/* =========================== */}}
+{{- $name := .Name -}}
+{{- $paramsStruct := .ParamsStruct -}}
+
+{{/* =========================== */}}
+{{/*        main function        */}}
+{{/* =========================== */}}
+// {{ $name }} executes in a transaction:
+//
+{{- range $f := .Funcs }}
+//    {{ $f.FuncName }}
+{{- end }}
+//
+// A transaction is begun (and committed or rolled back) if db_ is a TxBeginner
+// (e.g. *sql.DB), otherwise (e.g. *sql.Tx) statements are executed in db_ directly.
+func {{ $name }}(ctx_ {{ $ctx }}.Context, db_ DBer{{ range $arg := .Args }}, {{ $arg.Name }} {{ typeName $arg.Type }}{{ end }}) (err_ error) {
+
+	// - Begin transaction.
+	if beginner_, ok_ := db_.(TxBeginner); ok_ {
+		tx_, beginErr_ := beginner_.BeginTx(ctx_, nil)
+		if beginErr_ != nil {
+			return beginErr_
+		}
+		defer func() {
+			if r_ := recover(); r_ != nil {
+				tx_.Rollback()
+				panic(r_)
+			}
+			if err_ != nil {
+				tx_.Rollback()
+				return
+			}
+			err_ = tx_.Commit()
+		}()
+		db_ = tx_
+	}
+{{- range $f := .Funcs }}
+	{{- $funcName := $f.FuncName }}
+
+	// - {{ $funcName }}.
+	if {{ if $f.Batch }}_, {{ end }}_, err_ = {{ $funcName }}(ctx_, db_
+	{{- if index $paramsStruct $funcName -}}
+	, {{ $funcName }}Params{
+		{{- range $i, $arg := $f.Args }}{{ if $i }}, {{ end }}{{ $arg.FieldName }}: {{ $arg.Name }}{{ end -}}
+	}
+	{{- else -}}
+	{{- range $arg := $f.RequiredArgs }}, {{ $arg.Name }}{{ end -}}
+	{{- if $f.OptionalArgs -}}
+	, &{{ $funcName }}Opts{
+		{{- range $i, $arg := $f.OptionalArgs }}{{ if $i }}, {{ end }}{{ $arg.FieldName }}: {{ $arg.Name }}{{ end -}}
+	}
+	{{- end -}}
+	{{- end -}}
+	); err_ != nil {
+		return err_
+	}
+{{- end }}
+	return nil
+
+}
+
+
+`)
+
+}
@@ -13,6 +13,7 @@ var (
 	GitHash                     = "Unknwon"
 	identRe      *regexp.Regexp = regexp.MustCompile(`[^A-Za-z]*([A-Za-z])([A-Za-z0-9]*)`)
 	exactIdentRe *regexp.Regexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	nonAlnumRe   *regexp.Regexp = regexp.MustCompile(`[^A-Za-z0-9]+`)
 )
 
 // Is it a valid identifier?
@@ -45,6 +46,30 @@ func CamelCase(s string) string {
 	return string(b)
 }
 
+// Convert a string to snake case. Example: "SnakeCase" -> "snake_case"
+func SnakeCase(s string) string {
+	parts := []string{}
+	for _, chunk := range nonAlnumRe.Split(s, -1) {
+		rs := []rune(chunk)
+		if len(rs) == 0 {
+			continue
+		}
+		start := 0
+		for i := 1; i < len(rs); i++ {
+			if !unicode.IsUpper(rs[i]) {
+				continue
+			}
+			// "aB" "1B" or "ABc"
+			if !unicode.IsUpper(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				parts = append(parts, string(rs[start:i]))
+				start = i
+			}
+		}
+		parts = append(parts, string(rs[start:]))
+	}
+	return strings.ToLower(strings.Join(parts, "_"))
+}
+
 // Recover and capture the error.
 func RecoverErr(err *error) bool {
 	r := recover()
@@ -18,6 +18,13 @@ func testPascalCase(t *testing.T, s string, expect string) {
 	}
 }
 
+func testSnakeCase(t *testing.T, s string, expect string) {
+	r := SnakeCase(s)
+	if r != expect {
+		t.Errorf("%q: %q != %q\n", s, r, expect)
+	}
+}
+
 func TestCamelCase(t *testing.T) {
 	testCamelCase(t, "hello", "hello")
 	testCamelCase(t, "hello   world", "helloWorld")
@@ -31,3 +38,13 @@ func TestPascalCase(t *testing.T) {
 	testPascalCase(t, "  hello   world", "HelloWorld")
 	testPascalCase(t, "_hello___world", "HelloWorld")
 }
+
+func TestSnakeCase(t *testing.T) {
+	testSnakeCase(t, "hello", "hello")
+	testSnakeCase(t, "fill_time", "fill_time")
+	testSnakeCase(t, "fillTime", "fill_time")
+	testSnakeCase(t, "UserID", "user_id")
+	testSnakeCase(t, "HTTPServer", "http_server")
+	testSnakeCase(t, "  hello   World", "hello_world")
+	testSnakeCase(t, "now()", "now")
+}